@@ -0,0 +1,169 @@
+package cereal
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+)
+
+// A filtered index is preceded by a Bloom filter block over the keys of the index:
+//
+//	uvarint  number of hash functions
+//	uvarint  length of bit array in bytes
+//	[]byte   bit array
+//
+// and the index footer records the offset of both blocks:
+//
+//	[8]byte  big-endian offset of the filter block
+//	[8]byte  big-endian offset of the index block
+//	[4]byte  filterMagic
+
+var filterMagic = []byte("CIDF")
+
+// filterFooterLen is the length of the footer pointing at the filter and index blocks.
+var filterFooterLen = 16 + len(filterMagic)
+
+// maxFilterHashes is the most hash functions used by a filter.
+var maxFilterHashes = 30
+
+// bloomFilter is a set of keys which may report false positives but not false negatives.
+type bloomFilter struct {
+	hashes int
+	bits   []byte
+}
+
+// newBloomFilter will return a filter holding the keys using about bitsPerKey bits for each key.
+func newBloomFilter(keys []string, bitsPerKey int) *bloomFilter {
+	// ln 2 hash functions per bit minimises the false positive rate
+	hashes := int(math.Round(float64(bitsPerKey) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	} else if hashes > maxFilterHashes {
+		hashes = maxFilterHashes
+	}
+
+	bits := len(keys) * bitsPerKey
+	if bits < 64 {
+		bits = 64
+	}
+	f := &bloomFilter{
+		hashes: hashes,
+		bits:   make([]byte, (bits+7)/8),
+	}
+	for _, key := range keys {
+		f.each(key, func(bit uint32) bool {
+			f.bits[bit/8] |= 1 << (bit % 8)
+			return true
+		})
+	}
+	return f
+}
+
+// each will call fn with each bit of the key until fn returns false, using double hashing.
+func (f *bloomFilter) each(key string, fn func(bit uint32) bool) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := uint32(sum), uint32(sum>>32)
+
+	// An even or zero step would revisit the same bits
+	h2 |= 1
+
+	n := uint32(len(f.bits) * 8)
+	for i := 0; i < f.hashes; i++ {
+		if !fn((h1 + uint32(i)*h2) % n) {
+			return
+		}
+	}
+}
+
+func (f *bloomFilter) mayContain(key string) bool {
+	found := true
+	f.each(key, func(bit uint32) bool {
+		found = f.bits[bit/8]&(1<<(bit%8)) != 0
+		return found
+	})
+	return found
+}
+
+// SetBloomFilter will write a Bloom filter over the keys of the index using about bitsPerKey bits for each key, so
+// readers can rule out keys without reading the index. A bitsPerKey of 0 disables the filter. The filter is only
+// written if the index is enabled.
+func (w *Writer) SetBloomFilter(bitsPerKey int) {
+	w.filterBitsPerKey = bitsPerKey
+}
+
+// writeFilter will write the filter block over the keys of the index.
+func (w *Writer) writeFilter() error {
+	var keys []string
+	for _, e := range w.index {
+		if e.Key != "" {
+			keys = append(keys, e.Key)
+		}
+	}
+	f := newBloomFilter(keys, w.filterBitsPerKey)
+
+	if err := w.appendUvarint(uint64(f.hashes)); err != nil {
+		return err
+	}
+	return w.appendBytes(f.bits)
+}
+
+// MayContain will report whether the index may hold the key. A false result means the key is definitely not in the
+// index. Content with an index but no filter may contain any key.
+func (r *Reader) MayContain(key string) (bool, error) {
+	if r.err != nil {
+		return false, r.err
+	}
+	if !r.filterLoaded {
+		offset, err := r.r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return false, err
+		}
+		filter, err := r.readFilter()
+		if err != nil {
+			return false, err
+		}
+		if _, err = r.r.Seek(offset, io.SeekStart); err != nil {
+			return false, err
+		}
+		r.filter = filter
+		r.filterLoaded = true
+	}
+
+	if r.filter == nil {
+		return true, nil
+	}
+	return r.filter.mayContain(key), nil
+}
+
+func (r *Reader) readFilter() (*bloomFilter, error) {
+	footer, err := r.readIndexFooter()
+	if err != nil {
+		return nil, err
+	}
+	if !footer.filtered {
+		return nil, nil
+	}
+
+	if _, err = r.r.Seek(int64(footer.filter), io.SeekStart); err != nil {
+		return nil, err
+	}
+	hashes, _, err := r.readUint()
+	if err != nil {
+		return nil, err
+	}
+	len, _, err := r.readUint()
+	if err != nil {
+		return nil, err
+	}
+	if hashes == 0 || hashes > uint64(maxFilterHashes) || len == 0 || len > footer.index-footer.filter {
+		return nil, fmt.Errorf("corrupt filter: %d hashes over %d bytes", hashes, len)
+	}
+	bits, err := r.readSlice(len, false)
+	if err != nil {
+		return nil, err
+	}
+	return &bloomFilter{hashes: int(hashes), bits: bits}, nil
+}
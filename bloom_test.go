@@ -0,0 +1,93 @@
+package cereal
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestReader_MayContain(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	w.SetIndexed(true)
+	w.SetBloomFilter(10)
+	assert.NilError(t, w.SetSigningKey([]byte("secret")))
+	for i := 0; i < 1000; i++ {
+		_, _, err := w.WriteKeyed(fmt.Sprintf("key %d", i), int64(i))
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, w.Close())
+
+	r := NewReaderFromBuffer(buf.Bytes())
+	assert.NilError(t, r.Verify([]byte("secret")))
+	for i := 0; i < 1000; i++ {
+		ok, err := r.MayContain(fmt.Sprintf("key %d", i))
+		assert.NilError(t, err)
+		assert.Assert(t, ok, i)
+	}
+
+	// About 1% of absent keys are false positives with 10 bits per key
+	positives := 0
+	for i := 1000; i < 11000; i++ {
+		ok, err := r.MayContain(fmt.Sprintf("key %d", i))
+		assert.NilError(t, err)
+		if ok {
+			positives++
+		}
+	}
+	assert.Assert(t, positives < 300, positives)
+
+	// The index is still readable
+	val, _, err := r.ReadKey("key 500")
+	assert.NilError(t, err)
+	assert.Equal(t, val, int64(500))
+	index, err := r.Index()
+	assert.NilError(t, err)
+	assert.Equal(t, len(index), 1000)
+}
+
+func TestReader_MayContainNoFilter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	w.SetIndexed(true)
+	_, _, err := w.WriteKeyed("a", "b")
+	assert.NilError(t, err)
+	assert.NilError(t, w.Close())
+
+	ok, err := NewReaderFromBuffer(buf.Bytes()).MayContain("anything")
+	assert.NilError(t, err)
+	assert.Assert(t, ok)
+
+	// Filter is written even without keys
+	buf.Reset()
+	w = NewWriterFromBuffer(buf)
+	w.SetIndexed(true)
+	w.SetBloomFilter(10)
+	_, _, err = w.Write("unkeyed")
+	assert.NilError(t, err)
+	assert.NilError(t, w.Close())
+	ok, err = NewReaderFromBuffer(buf.Bytes()).MayContain("anything")
+	assert.NilError(t, err)
+	assert.Assert(t, !ok)
+
+	_, err = NewReaderFromBuffer([]byte{1, 2}).MayContain("a")
+	assert.Equal(t, err, ErrNoIndex)
+}
+
+func TestBloomFilter_DistinctBits(t *testing.T) {
+	// With a power of two bits every probe is distinct only if the step is odd
+	f := &bloomFilter{hashes: 7, bits: make([]byte, 2)}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprint(random.Uint64())
+		seen := map[uint32]bool{}
+		f.each(key, func(bit uint32) bool {
+			assert.Assert(t, !seen[bit], "key %q probes bit %d twice", key, bit)
+			seen[bit] = true
+			return true
+		})
+	}
+}
@@ -0,0 +1,109 @@
+// Package cbor transcodes between cereal values and CBOR, as specified by RFC 8949.
+//
+// Values map to CBOR as follows:
+//
+//	bool                    simple value true or false
+//	int64                   unsigned or negative integer
+//	uint64                  unsigned integer
+//	float64                 double precision float
+//	string                  text string
+//	[]byte                  byte string
+//	[]string                array of text strings
+//	map[string]interface{}  map with text string keys, in deterministic key order
+//	time.Time               tag 1 epoch seconds, or tag 0 RFC 3339 text when it has fractional seconds
+//	*big.Int                tag 2 or 3 bignum, when out of range of the integer major types
+//	nil                     null
+//
+// Cereal has no null, array of mixed types, time or bignum data types. Times are transcoded to cereal as Integer Unix
+// nanoseconds and bignums as Integer or UnsignedInteger when in range; null, arrays holding other than text strings
+// and out of range bignums cannot be transcoded to cereal.
+package cbor
+
+import (
+	"io"
+
+	"github.com/bt/cereal"
+	"github.com/bt/cereal/internal/transcode"
+)
+
+// Major types.
+const (
+	majorUint   byte = 0
+	majorNeg    byte = 1
+	majorBytes  byte = 2
+	majorText   byte = 3
+	majorArray  byte = 4
+	majorMap    byte = 5
+	majorTag    byte = 6
+	majorSimple byte = 7
+)
+
+// Additional information of the initial byte.
+const (
+	info8          byte = 24
+	info16         byte = 25
+	info32         byte = 26
+	info64         byte = 27
+	infoIndefinite byte = 31
+)
+
+// Simple values and floats of major type 7.
+const (
+	simpleFalse     byte = 20
+	simpleTrue      byte = 21
+	simpleNull      byte = 22
+	simpleUndefined byte = 23
+	breakCode       byte = 0xff
+)
+
+// Tags.
+const (
+	tagDateTime    = 0
+	tagEpoch       = 1
+	tagPositiveBig = 2
+	tagNegativeBig = 3
+)
+
+// ValueEncoder makes a cereal.Writer write CBOR, when set with Writer.SetValueEncoder.
+var ValueEncoder cereal.ValueEncoder = valueEncoder{}
+
+type valueEncoder struct{}
+
+func (valueEncoder) EncodeValue(w io.Writer, v interface{}) error {
+	return Encode(w, v)
+}
+
+// ToCBOR will read cereal values until the end of the reader and write each as CBOR.
+func ToCBOR(r *cereal.Reader, w io.Writer) error {
+	for {
+		val, _, err := r.Read(cereal.Any)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err = Encode(w, val); err != nil {
+			return err
+		}
+	}
+}
+
+// FromCBOR will read CBOR values until the end of the reader and write each as a cereal value.
+func FromCBOR(r io.Reader, w *cereal.Writer) error {
+	d := NewDecoder(r)
+	for {
+		val, err := d.Decode()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if val, err = transcode.ToCereal(val, "null"); err != nil {
+			return err
+		}
+		if _, _, err = w.Write(val); err != nil {
+			return err
+		}
+	}
+}
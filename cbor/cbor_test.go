@@ -0,0 +1,194 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/bt/cereal"
+	"gotest.tools/assert"
+)
+
+func bigInt(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 10)
+	return n
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+		decoded  interface{}
+	}{
+		// Examples from RFC 8949 appendix A
+		{int64(0), "00", int64(0)},
+		{int64(23), "17", int64(23)},
+		{int64(24), "1818", int64(24)},
+		{int64(1000), "1903e8", int64(1000)},
+		{int64(1000000), "1a000f4240", int64(1000000)},
+		{uint64(math.MaxUint64), "1bffffffffffffffff", uint64(math.MaxUint64)},
+		{int64(-1), "20", int64(-1)},
+		{int64(-1000), "3903e7", int64(-1000)},
+		{int64(math.MinInt64), "3b7fffffffffffffff", int64(math.MinInt64)},
+		{bigInt("18446744073709551616"), "c249010000000000000000", bigInt("18446744073709551616")},
+		{bigInt("-18446744073709551617"), "c349010000000000000000", bigInt("-18446744073709551617")},
+		{bigInt("-18446744073709551616"), "3bffffffffffffffff", bigInt("-18446744073709551616")},
+		{1.1, "fb3ff199999999999a", 1.1},
+		{float32(100000), "fa47c35000", 100000.0},
+		{false, "f4", false},
+		{true, "f5", true},
+		{nil, "f6", nil},
+		{time.Unix(1363896240, 0).UTC(), "c11a514b67b0", time.Unix(1363896240, 0).UTC()},
+		{time.Unix(1363896240, 500000000).UTC(), "c076323031332d30332d32315432303a30343a30302e355a", time.Unix(1363896240, 500000000).UTC()},
+		{[]byte{1, 2, 3, 4}, "4401020304", []byte{1, 2, 3, 4}},
+		{"IETF", "6449455446", "IETF"},
+		{"ü", "62c3bc", "ü"},
+		{[]string{}, "80", []interface{}{}},
+		{[]interface{}{int64(1), []interface{}{int64(2)}}, "82018102", []interface{}{int64(1), []interface{}{int64(2)}}},
+		{map[string]interface{}{"b": []string{"c"}, "a": int64(1), "aa": ""}, "a3616101616281616362616160", map[string]interface{}{"a": int64(1), "b": []interface{}{"c"}, "aa": ""}},
+	}
+	for _, tt := range tests {
+		buf := new(bytes.Buffer)
+		assert.NilError(t, Encode(buf, tt.value))
+		assert.Equal(t, hex.EncodeToString(buf.Bytes()), tt.expected)
+
+		val, err := NewDecoder(buf).Decode()
+		assert.NilError(t, err)
+		if n, ok := tt.decoded.(*big.Int); ok {
+			assert.Equal(t, val.(*big.Int).Cmp(n), 0)
+			continue
+		}
+		assert.DeepEqual(t, val, tt.decoded)
+	}
+
+	assert.Error(t, Encode(new(bytes.Buffer), struct{}{}), "cannot encode value '{}' of type struct {} as CBOR")
+}
+
+func TestDecode(t *testing.T) {
+	// Examples from RFC 8949 appendix A which are not produced by Encode
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"f93c00", 1.0},
+		{"f97bff", 65504.0},
+		{"f90001", 5.960464477539063e-08},
+		{"f9fc00", math.Inf(-1)},
+		{"c1fb41d452d9ec200000", time.Unix(1363896240, 500000000).UTC()},
+		{"c074323031332d30332d32315432303a30343a30305a", time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)},
+		{"d74401020304", []byte{1, 2, 3, 4}},
+		{"f7", nil},
+		{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"9f018202039f0405ffff", []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{"bf61610161629f0203ffff", map[string]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+	}
+	for _, tt := range tests {
+		b, err := hex.DecodeString(tt.input)
+		assert.NilError(t, err)
+		val, err := NewDecoder(bytes.NewReader(b)).Decode()
+		assert.NilError(t, err)
+		assert.DeepEqual(t, val, tt.expected)
+	}
+}
+
+func TestWriter_ValueEncoder(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := cereal.NewWriterFromBuffer(buf)
+	w.SetValueEncoder(ValueEncoder)
+	_, length, err := w.Write(map[string]interface{}{"a": int64(-1)})
+	assert.NilError(t, err)
+	assert.Equal(t, length, 4)
+	assert.Equal(t, hex.EncodeToString(buf.Bytes()), "a1616120")
+}
+
+func TestTranscode(t *testing.T) {
+	values := []interface{}{
+		map[string]interface{}{"name": "x", "n": int64(-4), "tags": []string{"a", "b"}, "raw": []byte{0}},
+		uint64(math.MaxUint64),
+		2.5,
+		true,
+		[]string{},
+	}
+
+	buf := new(bytes.Buffer)
+	w := cereal.NewWriterFromBuffer(buf)
+	for _, v := range values {
+		_, _, err := w.Write(v)
+		assert.NilError(t, err)
+	}
+
+	encoded := new(bytes.Buffer)
+	assert.NilError(t, ToCBOR(cereal.NewReaderFromBuffer(buf.Bytes()), encoded))
+
+	// Times and in range bignums are written as integers
+	assert.NilError(t, Encode(encoded, time.Unix(2, 3)))
+	assert.NilError(t, Encode(encoded, bigInt("-5")))
+	values = append(values, int64(2000000003), int64(-5))
+
+	out := new(bytes.Buffer)
+	assert.NilError(t, FromCBOR(encoded, cereal.NewWriterFromBuffer(out)))
+	r := cereal.NewReaderFromBuffer(out.Bytes())
+	for _, expected := range values {
+		val, _, err := r.Read(cereal.Any)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, val, expected)
+	}
+	_, _, err := r.Read(cereal.Any)
+	assert.Equal(t, err, io.EOF)
+}
+
+func TestFromCBOR_Errors(t *testing.T) {
+	tests := map[string]string{
+		"f6":                     "cannot transcode null to cereal",
+		"820161":                 "unexpected EOF",
+		"82016161":               "cannot transcode array with non-string element '1' to cereal",
+		"a10101":                 "cannot decode map key '1' of type int64, keys must be text strings",
+		"c249010000000000000000": "cannot transcode bignum 18446744073709551616 to cereal, out of range",
+		"1c":                     "invalid CBOR additional information 28",
+		"ff":                     "unexpected CBOR break",
+		"f0":                     "unsupported CBOR simple value 16",
+		"1f":                     "invalid indefinite length for CBOR major type 0",
+		"5f6161ff":               "invalid chunk of indefinite length CBOR string",
+		"62c328":                 "invalid UTF-8 in CBOR text string",
+		"c06161":                 "invalid CBOR date/time: parsing time \"a\" as \"2006-01-02T15:04:05.999999999Z07:00\": cannot parse \"a\" as \"2006\"",
+		"c16161":                 "invalid CBOR epoch time content of type string",
+		"c201":                   "invalid CBOR bignum content of type int64",
+	}
+	for input, expected := range tests {
+		b, err := hex.DecodeString(input)
+		assert.NilError(t, err)
+		err = FromCBOR(bytes.NewReader(b), cereal.NewWriterFromBuffer(new(bytes.Buffer)))
+		assert.Error(t, err, expected, input)
+	}
+}
+
+func TestDecoder_MaxDepth(t *testing.T) {
+	b, err := hex.DecodeString("81a161618101")
+	assert.NilError(t, err)
+
+	d := NewDecoder(bytes.NewReader(b))
+	d.SetMaxDepth(2)
+	_, err = d.Decode()
+	assert.Error(t, err, "decoding limit exceeded: MaxDepth of 2, got 3")
+
+	d = NewDecoder(bytes.NewReader(b))
+	d.SetMaxDepth(3)
+	val, err := d.Decode()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, val, []interface{}{map[string]interface{}{"a": []interface{}{int64(1)}}})
+
+	// Deeply nested arrays and tags are refused by default
+	for _, prefix := range []byte{0x81, 0xc6} {
+		deep := bytes.Repeat([]byte{prefix}, DefaultMaxDepth+1)
+		_, err = NewDecoder(bytes.NewReader(append(deep, 0x01))).Decode()
+		var limitErr *cereal.LimitExceededError
+		assert.Assert(t, errors.As(err, &limitErr))
+		assert.Equal(t, limitErr.Limit, "MaxDepth")
+	}
+}
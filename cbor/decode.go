@@ -0,0 +1,305 @@
+package cbor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bt/cereal/internal/transcode"
+)
+
+// DefaultMaxDepth is the deepest nesting of arrays, maps and tags a decoder reads unless set otherwise.
+const DefaultMaxDepth = transcode.DefaultMaxDepth
+
+// Decoder reads CBOR values from a stream. It may read beyond the last value it decodes.
+type Decoder struct {
+	r     *bufio.Reader
+	depth transcode.Depth
+}
+
+// NewDecoder will return a new decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r:     bufio.NewReader(r),
+		depth: transcode.Depth{Max: DefaultMaxDepth},
+	}
+}
+
+// SetMaxDepth will set the deepest nesting of arrays, maps and tags decoded, where a top-level array or map has a
+// depth of 1, like cereal.ReaderOptions.MaxDepth. Zero is unlimited.
+func (d *Decoder) SetMaxDepth(n int) {
+	d.depth.Max = n
+}
+
+// Decode will read the next value, returning io.EOF at the end of the stream. Integers are returned as int64, or
+// uint64 or *big.Int when out of range of int64, floats as float64, text strings as string, byte strings as []byte,
+// arrays as []interface{}, maps as map[string]interface{}, times as time.Time, bignums as *big.Int and null and
+// undefined as nil. Other tags are ignored and their content returned.
+func (d *Decoder) Decode() (interface{}, error) {
+	if _, err := d.r.Peek(1); err != nil {
+		return nil, err
+	}
+
+	val, err := d.decodeValue()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return val, err
+}
+
+// head is the initial byte and argument of a data item.
+type head struct {
+	major      byte
+	info       byte
+	arg        uint64
+	indefinite bool
+}
+
+func (d *Decoder) readHead() (head, error) {
+	ib, err := d.r.ReadByte()
+	if err != nil {
+		return head{}, err
+	}
+
+	h := head{major: ib >> 5, info: ib & 0x1f}
+	switch {
+	case h.info < info8:
+		h.arg = uint64(h.info)
+	case h.info <= info64:
+		buf, err := transcode.ReadN(d.r, 1<<(h.info-info8))
+		if err != nil {
+			return head{}, err
+		}
+		for _, b := range buf {
+			h.arg = h.arg<<8 | uint64(b)
+		}
+	case h.info == infoIndefinite:
+		h.indefinite = true
+	default:
+		return head{}, fmt.Errorf("invalid CBOR additional information %d", h.info)
+	}
+	return h, nil
+}
+
+// isBreak will consume the break code ending an indefinite length item, if it is next.
+func (d *Decoder) isBreak() (bool, error) {
+	b, err := d.r.Peek(1)
+	if err != nil {
+		return false, err
+	}
+	if b[0] != breakCode {
+		return false, nil
+	}
+	_, err = d.r.ReadByte()
+	return true, err
+}
+
+func (d *Decoder) decodeValue() (interface{}, error) {
+	h, err := d.readHead()
+	if err != nil {
+		return nil, err
+	}
+	if h.indefinite && (h.major < majorBytes || h.major == majorTag) {
+		return nil, fmt.Errorf("invalid indefinite length for CBOR major type %d", h.major)
+	}
+	if h.major == majorArray || h.major == majorMap || h.major == majorTag {
+		defer d.depth.Leave()
+		if err := d.depth.Enter(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch h.major {
+	case majorUint:
+		if h.arg > math.MaxInt64 {
+			return h.arg, nil
+		}
+		return int64(h.arg), nil
+	case majorNeg:
+		if h.arg > math.MaxInt64 {
+			n := new(big.Int).SetUint64(h.arg)
+			return n.Neg(n).Sub(n, big.NewInt(1)), nil
+		}
+		return -1 - int64(h.arg), nil
+	case majorBytes, majorText:
+		buf, err := d.decodeString(h)
+		if err != nil {
+			return nil, err
+		}
+		if h.major == majorBytes {
+			return buf, nil
+		}
+		if !utf8.Valid(buf) {
+			return nil, fmt.Errorf("invalid UTF-8 in CBOR text string")
+		}
+		return string(buf), nil
+	case majorArray:
+		a := []interface{}{}
+		for i := uint64(0); h.indefinite || i < h.arg; i++ {
+			if h.indefinite {
+				done, err := d.isBreak()
+				if err != nil {
+					return nil, err
+				}
+				if done {
+					return a, nil
+				}
+			}
+			v, err := d.decodeValue()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		return a, nil
+	case majorMap:
+		m := map[string]interface{}{}
+		for i := uint64(0); h.indefinite || i < h.arg; i++ {
+			if h.indefinite {
+				done, err := d.isBreak()
+				if err != nil {
+					return nil, err
+				}
+				if done {
+					return m, nil
+				}
+			}
+			k, err := d.decodeValue()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, fmt.Errorf("cannot decode map key '%v' of type %T, keys must be text strings", k, k)
+			}
+			if m[key], err = d.decodeValue(); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case majorTag:
+		content, err := d.decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		return decodeTag(h.arg, content)
+	default:
+		return decodeSimple(h)
+	}
+}
+
+// decodeString will read the content of a byte or text string, joining the chunks of indefinite length strings.
+func (d *Decoder) decodeString(h head) ([]byte, error) {
+	if !h.indefinite {
+		return transcode.ReadN(d.r, h.arg)
+	}
+
+	buf := []byte{}
+	for {
+		done, err := d.isBreak()
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return buf, nil
+		}
+		chunk, err := d.readHead()
+		if err != nil {
+			return nil, err
+		}
+		if chunk.major != h.major || chunk.indefinite {
+			return nil, fmt.Errorf("invalid chunk of indefinite length CBOR string")
+		}
+		b, err := transcode.ReadN(d.r, chunk.arg)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, b...)
+	}
+}
+
+func decodeTag(tag uint64, content interface{}) (interface{}, error) {
+	switch tag {
+	case tagDateTime:
+		s, ok := content.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid CBOR date/time content of type %T", content)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CBOR date/time: %v", err)
+		}
+		return t, nil
+	case tagEpoch:
+		switch v := content.(type) {
+		case int64:
+			return time.Unix(v, 0).UTC(), nil
+		case float64:
+			sec, frac := math.Modf(v)
+			return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+		default:
+			return nil, fmt.Errorf("invalid CBOR epoch time content of type %T", content)
+		}
+	case tagPositiveBig, tagNegativeBig:
+		b, ok := content.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("invalid CBOR bignum content of type %T", content)
+		}
+		n := new(big.Int).SetBytes(b)
+		if tag == tagNegativeBig {
+			n.Neg(n).Sub(n, big.NewInt(1))
+		}
+		return n, nil
+	default:
+		return content, nil
+	}
+}
+
+func decodeSimple(h head) (interface{}, error) {
+	switch h.info {
+	case simpleFalse:
+		return false, nil
+	case simpleTrue:
+		return true, nil
+	case simpleNull, simpleUndefined:
+		return nil, nil
+	case info16:
+		return halfToFloat64(uint16(h.arg)), nil
+	case info32:
+		return float64(math.Float32frombits(uint32(h.arg))), nil
+	case info64:
+		return math.Float64frombits(h.arg), nil
+	case infoIndefinite:
+		return nil, fmt.Errorf("unexpected CBOR break")
+	default:
+		return nil, fmt.Errorf("unsupported CBOR simple value %d", h.arg)
+	}
+}
+
+// halfToFloat64 will convert the bits of a half precision float.
+func halfToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
@@ -0,0 +1,171 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// Encode will write the value as CBOR.
+func Encode(w io.Writer, v interface{}) error {
+	buf, err := appendValue(nil, v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+func appendValue(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, majorSimple<<5|simpleNull), nil
+	case bool:
+		if v {
+			return append(b, majorSimple<<5|simpleTrue), nil
+		}
+		return append(b, majorSimple<<5|simpleFalse), nil
+	case int:
+		return appendInt(b, int64(v)), nil
+	case int8:
+		return appendInt(b, int64(v)), nil
+	case int16:
+		return appendInt(b, int64(v)), nil
+	case int32:
+		return appendInt(b, int64(v)), nil
+	case int64:
+		return appendInt(b, v), nil
+	case uint:
+		return appendHead(b, majorUint, uint64(v)), nil
+	case uint8:
+		return appendHead(b, majorUint, uint64(v)), nil
+	case uint16:
+		return appendHead(b, majorUint, uint64(v)), nil
+	case uint32:
+		return appendHead(b, majorUint, uint64(v)), nil
+	case uint64:
+		return appendHead(b, majorUint, v), nil
+	case float32:
+		b = append(b, majorSimple<<5|info32)
+		return appendUint32(b, math.Float32bits(v)), nil
+	case float64:
+		b = append(b, majorSimple<<5|info64)
+		return appendUint64(b, math.Float64bits(v)), nil
+	case string:
+		return append(appendHead(b, majorText, uint64(len(v))), v...), nil
+	case []byte:
+		return append(appendHead(b, majorBytes, uint64(len(v))), v...), nil
+	case []string:
+		b = appendHead(b, majorArray, uint64(len(v)))
+		for _, s := range v {
+			b = append(appendHead(b, majorText, uint64(len(s))), s...)
+		}
+		return b, nil
+	case []interface{}:
+		b = appendHead(b, majorArray, uint64(len(v)))
+		for _, e := range v {
+			var err error
+			if b, err = appendValue(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		return appendMap(b, v)
+	case time.Time:
+		if v.Nanosecond() == 0 {
+			return appendInt(appendHead(b, majorTag, tagEpoch), v.Unix()), nil
+		}
+		s := v.Format(time.RFC3339Nano)
+		return append(appendHead(appendHead(b, majorTag, tagDateTime), majorText, uint64(len(s))), s...), nil
+	case *big.Int:
+		return appendBig(b, v), nil
+	default:
+		return nil, fmt.Errorf("cannot encode value '%v' of type %T as CBOR", v, v)
+	}
+}
+
+// appendHead will append the initial byte and argument using the shortest form.
+func appendHead(b []byte, major byte, n uint64) []byte {
+	switch {
+	case n < uint64(info8):
+		return append(b, major<<5|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major<<5|info8, byte(n))
+	case n <= math.MaxUint16:
+		return append(b, major<<5|info16, byte(n>>8), byte(n))
+	case n <= math.MaxUint32:
+		return appendUint32(append(b, major<<5|info32), uint32(n))
+	default:
+		return appendUint64(append(b, major<<5|info64), n)
+	}
+}
+
+func appendInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendHead(b, majorNeg, uint64(-1-v))
+	}
+	return appendHead(b, majorUint, uint64(v))
+}
+
+// appendMap will append the map with keys in the bytewise order of their encoding, as in deterministic encoding.
+func appendMap(b []byte, m map[string]interface{}) ([]byte, error) {
+	type encodedKey struct {
+		key     string
+		encoded []byte
+	}
+	keys := make([]encodedKey, 0, len(m))
+	for k := range m {
+		keys = append(keys, encodedKey{k, append(appendHead(nil, majorText, uint64(len(k))), k...)})
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i].encoded, keys[j].encoded) < 0
+	})
+
+	b = appendHead(b, majorMap, uint64(len(m)))
+	for _, k := range keys {
+		b = append(b, k.encoded...)
+		var err error
+		if b, err = appendValue(b, m[k.key]); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendBig will append the integer as a major type 0 or 1 integer when in range, otherwise as a bignum.
+func appendBig(b []byte, v *big.Int) []byte {
+	if v.Sign() >= 0 {
+		if v.IsUint64() {
+			return appendHead(b, majorUint, v.Uint64())
+		}
+		mag := v.Bytes()
+		return append(appendHead(appendHead(b, majorTag, tagPositiveBig), majorBytes, uint64(len(mag))), mag...)
+	}
+
+	// Negative values encode -1 - v
+	n := new(big.Int).Neg(v)
+	n.Sub(n, big.NewInt(1))
+	if n.IsUint64() {
+		return appendHead(b, majorNeg, n.Uint64())
+	}
+	mag := n.Bytes()
+	return append(appendHead(appendHead(b, majorTag, tagNegativeBig), majorBytes, uint64(len(mag))), mag...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
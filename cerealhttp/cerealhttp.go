@@ -0,0 +1,194 @@
+// Package cerealhttp writes and reads cereal encoded HTTP bodies.
+package cerealhttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/bt/cereal"
+)
+
+// MediaType is the media type of cereal encoded bodies.
+const MediaType = "application/x-cereal"
+
+// DefaultMaxBodySize is the largest request body Decode will read.
+const DefaultMaxBodySize int64 = 10 << 20
+
+// maxBodyDepth is the deepest nesting of maps in a request body which Decode will read.
+const maxBodyDepth = 100
+
+var (
+	// ErrUnsupportedMediaType is returned by Decode when the request body is not cereal encoded.
+	ErrUnsupportedMediaType = errors.New("request body is not " + MediaType)
+
+	// ErrBodyTooLarge is returned by Decode when the request body exceeds the limit.
+	ErrBodyTooLarge = errors.New("request body too large")
+)
+
+// Write will write the value as a cereal encoded response body with status OK.
+func Write(w http.ResponseWriter, v interface{}) error {
+	return WriteStatus(w, http.StatusOK, v)
+}
+
+// WriteStatus will write the value as a cereal encoded response body with the status.
+func WriteStatus(w http.ResponseWriter, status int, v interface{}) error {
+	buf := new(bytes.Buffer)
+	if err := encode(buf, v); err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", MediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func encode(buf *bytes.Buffer, v interface{}) error {
+	_, _, err := cereal.NewWriterFromBuffer(buf).Write(v)
+	return err
+}
+
+// Decode will read the cereal encoded request body of at most DefaultMaxBodySize bytes into the value v points to,
+// which must be of the type read or an interface it implements.
+func Decode(r *http.Request, v interface{}) error {
+	return DecodeLimit(r, v, DefaultMaxBodySize)
+}
+
+// DecodeLimit will read the cereal encoded request body of at most maxBodySize bytes into the value v points to, like
+// Decode.
+func DecodeLimit(r *http.Request, v interface{}, maxBodySize int64) error {
+	ptr := reflect.ValueOf(v)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return fmt.Errorf("cannot decode into %T, must be a non-nil pointer", v)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != MediaType {
+		return ErrUnsupportedMediaType
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > maxBodySize {
+		return ErrBodyTooLarge
+	}
+
+	// Values cannot be longer than the body, nor allocate more than the largest body
+	br := cereal.NewReaderFromBuffer(body)
+	br.SetOptions(cereal.ReaderOptions{
+		MaxLength:     len(body),
+		MaxEntries:    len(body),
+		MaxDepth:      maxBodyDepth,
+		MaxAllocation: int(maxBodySize),
+	})
+	val, _, err := br.Read(cereal.Any)
+	if err != nil {
+		return err
+	}
+	rv := reflect.ValueOf(val)
+	if !rv.Type().AssignableTo(ptr.Elem().Type()) {
+		return fmt.Errorf("cannot decode %T body into %T", val, v)
+	}
+	ptr.Elem().Set(rv)
+	return nil
+}
+
+// Accepts will report whether the request accepts cereal encoded responses. The media type must be listed
+// explicitly, wildcards do not match it.
+func Accepts(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil || mediaType != MediaType {
+				continue
+			}
+			if q := params["q"]; q != "" {
+				if f, err := strconv.ParseFloat(q, 64); err != nil || f <= 0 {
+					continue
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Negotiate will wrap the handler so cereal encoded responses are transcoded to JSON for clients which do not accept
+// cereal, using cereal.ToJSON.
+func Negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		if Accepts(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		jw := &jsonResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(jw, r)
+		jw.finish()
+	})
+}
+
+// jsonResponseWriter buffers cereal encoded responses to transcode them to JSON.
+type jsonResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	transcode   bool
+	status      int
+	buf         bytes.Buffer
+}
+
+func (w *jsonResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if mediaType != MediaType {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.transcode = true
+	w.status = status
+}
+
+func (w *jsonResponseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.transcode {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+// finish will write the transcoded response.
+func (w *jsonResponseWriter) finish() {
+	if !w.transcode {
+		return
+	}
+
+	body := new(bytes.Buffer)
+	if err := cereal.ToJSON(cereal.NewReaderFromBuffer(w.buf.Bytes()), body); err != nil {
+		w.Header().Del("Content-Length")
+		http.Error(w.ResponseWriter, "cannot transcode response to JSON: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(body.Len()))
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.Write(body.Bytes())
+}
@@ -0,0 +1,129 @@
+package cerealhttp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bt/cereal"
+	"gotest.tools/assert"
+)
+
+func encoded(t *testing.T, v interface{}) []byte {
+	buf := new(bytes.Buffer)
+	_, _, err := cereal.NewWriterFromBuffer(buf).Write(v)
+	assert.NilError(t, err)
+	return buf.Bytes()
+}
+
+func TestWrite(t *testing.T) {
+	rec := httptest.NewRecorder()
+	assert.NilError(t, WriteStatus(rec, http.StatusCreated, map[string]interface{}{"id": int64(7)}))
+	assert.Equal(t, rec.Code, http.StatusCreated)
+	assert.Equal(t, rec.Header().Get("Content-Type"), MediaType)
+
+	val, _, err := cereal.NewReaderFromBuffer(rec.Body.Bytes()).Read(cereal.Any)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, val, map[string]interface{}{"id": int64(7)})
+
+	rec = httptest.NewRecorder()
+	assert.ErrorContains(t, Write(rec, struct{}{}), "cannot write value")
+	assert.Equal(t, rec.Body.Len(), 0)
+}
+
+func TestDecode(t *testing.T) {
+	newRequest := func(contentType string, body []byte) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		return r
+	}
+
+	var m map[string]interface{}
+	assert.NilError(t, Decode(newRequest(MediaType, encoded(t, map[string]interface{}{"a": "b"})), &m))
+	assert.DeepEqual(t, m, map[string]interface{}{"a": "b"})
+
+	var v interface{}
+	assert.NilError(t, Decode(newRequest(MediaType+"; charset=binary", encoded(t, uint64(3))), &v))
+	assert.Equal(t, v, uint64(3))
+
+	var s string
+	assert.Error(t, Decode(newRequest(MediaType, encoded(t, int64(1))), &s), "cannot decode int64 body into *string")
+	assert.Error(t, Decode(newRequest(MediaType, nil), &s), "EOF")
+	assert.Error(t, Decode(newRequest(MediaType, nil), s), "cannot decode into string, must be a non-nil pointer")
+	assert.Equal(t, Decode(newRequest("application/json", []byte(`"a"`)), &s), ErrUnsupportedMediaType)
+
+	assert.Equal(t, DecodeLimit(newRequest(MediaType, encoded(t, "longer than eight")), &s, 8), ErrBodyTooLarge)
+	assert.NilError(t, DecodeLimit(newRequest(MediaType, encoded(t, "short")), &s, 8))
+	assert.Equal(t, s, "short")
+
+	// Lengths are limited by the body
+	body := []byte{byte(cereal.String)}
+	var length [binary.MaxVarintLen64]byte
+	body = append(body, length[:binary.PutUvarint(length[:], 1<<60)]...)
+	err := Decode(newRequest(MediaType, body), &s)
+	assert.Error(t, err, "decoding limit exceeded: MaxLength of 10, got 1152921504606846976")
+}
+
+func TestAccepts(t *testing.T) {
+	tests := map[string]bool{
+		"":                                     false,
+		"*/*":                                  false,
+		"application/json":                     false,
+		MediaType:                              true,
+		"application/json, " + MediaType:       true,
+		MediaType + ";q=0.5, application/json": true,
+		MediaType + ";q=0":                     false,
+	}
+	for accept, expected := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", accept)
+		assert.Equal(t, Accepts(r), expected, accept)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	handler := Negotiate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cereal":
+			WriteStatus(w, http.StatusAccepted, map[string]interface{}{"tags": []string{"a"}, "n": int64(1)})
+		case "/bad":
+			w.Header().Set("Content-Type", MediaType)
+			w.Write([]byte{byte(cereal.String), 5, 'a'})
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("plain"))
+		}
+	}))
+
+	tests := []struct {
+		path, accept string
+		status       int
+		contentType  string
+		body         string
+	}{
+		{"/cereal", MediaType, http.StatusAccepted, MediaType, string(encoded(t, map[string]interface{}{"n": int64(1), "tags": []string{"a"}}))},
+		{"/cereal", "application/json", http.StatusAccepted, "application/json", `{"n":1,"tags":["a"]}` + "\n"},
+		{"/cereal", "", http.StatusAccepted, "application/json", `{"n":1,"tags":["a"]}` + "\n"},
+		{"/plain", "", http.StatusOK, "text/plain", "plain"},
+		{"/bad", "", http.StatusInternalServerError, "text/plain; charset=utf-8", "cannot transcode response to JSON"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		r.Header.Set("Accept", tt.accept)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+
+		assert.Equal(t, rec.Code, tt.status, tt.path)
+		assert.Equal(t, rec.Header().Get("Content-Type"), tt.contentType, tt.path)
+		assert.Equal(t, rec.Header().Get("Vary"), "Accept")
+		if tt.path == "/cereal" && tt.accept == MediaType {
+			// Map order of the encoding may vary
+			assert.Equal(t, rec.Body.Len(), len(tt.body))
+		} else {
+			assert.Assert(t, strings.HasPrefix(rec.Body.String(), tt.body), rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Package cerealrpc implements net/rpc client and server codecs which use cereal as the wire format.
+//
+// Each request and response is a frame of a 4-byte big-endian length followed by cereal values: the service method,
+// the sequence number, for responses the error, and then the body. Bodies of responses with an error are omitted.
+// Bodies must be values, or pointers to values, which a cereal.Writer can write, and are read into pointers to the
+// same types or numeric types the value fits in.
+package cerealrpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"reflect"
+	"sync"
+
+	"github.com/bt/cereal"
+	"github.com/bt/cereal/internal/convert"
+)
+
+// maxFrameLen is the longest frame which will be read.
+var maxFrameLen uint32 = 64 << 20
+
+// maxFrameDepth is the deepest nesting of maps in a frame which will be read.
+const maxFrameDepth = 100
+
+// conn reads and writes frames over a connection.
+type conn struct {
+	rwc io.ReadWriteCloser
+	r   *bufio.Reader
+	w   *bufio.Writer
+
+	// Reader of the frame whose header has been read
+	frame *cereal.Reader
+
+	writeMu sync.Mutex
+	buf     bytes.Buffer
+}
+
+func newConn(rwc io.ReadWriteCloser) *conn {
+	return &conn{
+		rwc: rwc,
+		r:   bufio.NewReader(rwc),
+		w:   bufio.NewWriter(rwc),
+	}
+}
+
+// readFrame will read the next frame and return a reader for its values.
+func (c *conn) readFrame() (*cereal.Reader, error) {
+	var prefix [4]byte
+	if _, err := io.ReadFull(c.r, prefix[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(prefix[:])
+	if n > maxFrameLen {
+		return nil, fmt.Errorf("frame of %d bytes exceeds limit of %d bytes", n, maxFrameLen)
+	}
+
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(c.r, frame); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	// Values cannot be longer than the frame, nor allocate more than the longest frame
+	c.frame = cereal.NewReaderFromBuffer(frame)
+	c.frame.SetOptions(cereal.ReaderOptions{
+		MaxLength:     int(n),
+		MaxEntries:    int(n),
+		MaxDepth:      maxFrameDepth,
+		MaxAllocation: int(maxFrameLen),
+	})
+	return c.frame, nil
+}
+
+// writeFrame will write the values as a frame.
+func (c *conn) writeFrame(values ...interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.encode(values...); err != nil {
+		return err
+	}
+	return c.send()
+}
+
+// encode will write the values to the frame buffer.
+func (c *conn) encode(values ...interface{}) error {
+	c.buf.Reset()
+	w := cereal.NewWriterFromBuffer(&c.buf)
+	for _, v := range values {
+		if _, _, err := w.Write(v); err != nil {
+			return fmt.Errorf("cannot write body: %v", err)
+		}
+	}
+	return nil
+}
+
+// send will write the frame buffer as a frame.
+func (c *conn) send() error {
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], uint32(c.buf.Len()))
+	if _, err := c.w.Write(prefix[:]); err != nil {
+		return err
+	}
+	if _, err := c.w.Write(c.buf.Bytes()); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// readBody will read the body of the current frame into body. A nil body discards it.
+func (c *conn) readBody(body interface{}) error {
+	frame := c.frame
+	c.frame = nil
+	if body == nil || frame == nil {
+		return nil
+	}
+
+	val, _, err := frame.Read(cereal.Any)
+	if err != nil {
+		return err
+	}
+	return setBody(body, val)
+}
+
+func (c *conn) Close() error {
+	return c.rwc.Close()
+}
+
+// bodyValue will dereference pointers to the body.
+func bodyValue(body interface{}) interface{} {
+	v := reflect.ValueOf(body)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// setBody will set the value pointed to by body to the value read.
+func setBody(body interface{}, val interface{}) error {
+	ptr := reflect.ValueOf(body)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return fmt.Errorf("cannot read body into %T, must be a non-nil pointer", body)
+	}
+	target := ptr.Elem()
+	v := reflect.ValueOf(val)
+
+	if v.Type().AssignableTo(target.Type()) {
+		target.Set(v)
+		return nil
+	}
+
+	// Convert numbers which fit the target
+	if !convert.Number(target, val) {
+		return fmt.Errorf("cannot read %T body into %T", val, body)
+	}
+	return nil
+}
+
+type clientCodec struct {
+	*conn
+}
+
+// NewClientCodec will return a codec for a client connection.
+func NewClientCodec(rwc io.ReadWriteCloser) rpc.ClientCodec {
+	return &clientCodec{newConn(rwc)}
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	return c.writeFrame(r.ServiceMethod, r.Seq, bodyValue(body))
+}
+
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	frame, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+
+	for _, v := range []interface{}{&r.ServiceMethod, &r.Seq, &r.Error} {
+		val, _, err := frame.Read(cereal.Any)
+		if err != nil {
+			return err
+		}
+		if err = setBody(v, val); err != nil {
+			return fmt.Errorf("invalid response header: %v", err)
+		}
+	}
+	return nil
+}
+
+func (c *clientCodec) ReadResponseBody(body interface{}) error {
+	return c.readBody(body)
+}
+
+type serverCodec struct {
+	*conn
+}
+
+// NewServerCodec will return a codec for a server connection.
+func NewServerCodec(rwc io.ReadWriteCloser) rpc.ServerCodec {
+	return &serverCodec{newConn(rwc)}
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	frame, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+
+	for _, v := range []interface{}{&r.ServiceMethod, &r.Seq} {
+		val, _, err := frame.Read(cereal.Any)
+		if err != nil {
+			return err
+		}
+		if err = setBody(v, val); err != nil {
+			return fmt.Errorf("invalid request header: %v", err)
+		}
+	}
+	return nil
+}
+
+func (c *serverCodec) ReadRequestBody(body interface{}) error {
+	return c.readBody(body)
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	if r.Error != "" {
+		return c.writeFrame(r.ServiceMethod, r.Seq, r.Error)
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	// Report bodies which cannot be written to the client, which would otherwise wait for the response forever
+	if err := c.encode(r.ServiceMethod, r.Seq, r.Error, bodyValue(body)); err != nil {
+		if err = c.encode(r.ServiceMethod, r.Seq, err.Error()); err != nil {
+			return err
+		}
+	}
+	return c.send()
+}
+
+// NewClient will return a client using cereal over the connection.
+func NewClient(rwc io.ReadWriteCloser) *rpc.Client {
+	return rpc.NewClientWithCodec(NewClientCodec(rwc))
+}
+
+// Dial will connect to an RPC server at the address.
+func Dial(network, address string) (*rpc.Client, error) {
+	c, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(c), nil
+}
+
+// ServeConn will serve a single connection using cereal, blocking until the client hangs up.
+func ServeConn(rwc io.ReadWriteCloser) {
+	rpc.ServeCodec(NewServerCodec(rwc))
+}
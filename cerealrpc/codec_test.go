@@ -0,0 +1,152 @@
+package cerealrpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+
+	"github.com/bt/cereal"
+	"gotest.tools/assert"
+)
+
+type Service struct{}
+
+func (s *Service) Multiply(args map[string]interface{}, reply *int64) error {
+	*reply = args["a"].(int64) * args["b"].(int64)
+	return nil
+}
+
+func (s *Service) Join(args []string, reply *string) error {
+	*reply = fmt.Sprint(args)
+	return nil
+}
+
+func (s *Service) Half(args int, reply *int32) error {
+	*reply = int32(args / 2)
+	return nil
+}
+
+func (s *Service) Fail(args string, reply *string) error {
+	return errors.New("failed: " + args)
+}
+
+func (s *Service) Unwritable(args string, reply *[]int) error {
+	*reply = []int{1}
+	return nil
+}
+
+func newClient(t *testing.T) *rpc.Client {
+	server := rpc.NewServer()
+	assert.NilError(t, server.Register(&Service{}))
+
+	clientConn, serverConn := net.Pipe()
+	go server.ServeCodec(NewServerCodec(serverConn))
+	return NewClient(clientConn)
+}
+
+func TestCodec_Call(t *testing.T) {
+	client := newClient(t)
+	defer client.Close()
+
+	var product int64
+	assert.NilError(t, client.Call("Service.Multiply", map[string]interface{}{"a": int64(6), "b": int64(-7)}, &product))
+	assert.Equal(t, product, int64(-42))
+
+	var joined string
+	assert.NilError(t, client.Call("Service.Join", []string{"a", "b"}, &joined))
+	assert.Equal(t, joined, "[a b]")
+
+	// Numbers are converted to the types of the arguments and reply
+	var half int32
+	args := 9
+	assert.NilError(t, client.Call("Service.Half", &args, &half))
+	assert.Equal(t, half, int32(4))
+
+	var reply string
+	err := client.Call("Service.Fail", "bad input", &reply)
+	assert.Error(t, err, "failed: bad input")
+	err = client.Call("Service.Missing", "x", &reply)
+	assert.Error(t, err, "rpc: can't find method Service.Missing")
+
+	// Arguments of the wrong type fail the call but not the connection
+	err = client.Call("Service.Join", "not a slice", &joined)
+	assert.Error(t, err, "cannot read string body into *[]string")
+	err = client.Call("Service.Unwritable", "x", &reply)
+	assert.Error(t, err, "cannot write body: cannot write value, unknown data type for value: '[1]' (type: []int)")
+	err = client.Call("Service.Half", uint64(1)<<40, &half)
+	assert.NilError(t, err)
+
+	assert.NilError(t, client.Call("Service.Join", []string{"still", "connected"}, &joined))
+	assert.Equal(t, joined, "[still connected]")
+
+	// Replies of the wrong type shut down the client, as with other codecs
+	err = client.Call("Service.Half", 1<<40, &reply)
+	assert.Error(t, err, "reading body cannot read int64 body into *string")
+}
+
+func TestCodec_Concurrent(t *testing.T) {
+	client := newClient(t)
+	defer client.Close()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 50)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var product int64
+			if err := client.Call("Service.Multiply", map[string]interface{}{"a": int64(i), "b": int64(i)}, &product); err != nil {
+				errs[i] = err
+			} else if product != int64(i*i) {
+				errs[i] = fmt.Errorf("call %d: got %d", i, product)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NilError(t, err)
+	}
+}
+
+func TestCodec_FrameLimit(t *testing.T) {
+	defer func(n uint32) { maxFrameLen = n }(maxFrameLen)
+	maxFrameLen = 16
+
+	client := newClient(t)
+	defer client.Close()
+
+	var joined string
+	err := client.Call("Service.Join", []string{"this request is longer than the limit"}, &joined)
+	assert.Assert(t, err != nil)
+}
+
+func TestCodec_MalformedFrame(t *testing.T) {
+	server := rpc.NewServer()
+	assert.NilError(t, server.Register(&Service{}))
+	clientConn, serverConn := net.Pipe()
+	go server.ServeCodec(NewServerCodec(serverConn))
+	c := newConn(clientConn)
+	defer c.Close()
+
+	// A request whose body claims a string far longer than the frame
+	assert.NilError(t, c.encode("Service.Join", uint64(1)))
+	c.buf.WriteByte(byte(cereal.String))
+	var length [binary.MaxVarintLen64]byte
+	c.buf.Write(length[:binary.PutUvarint(length[:], 1<<60)])
+	assert.NilError(t, c.send())
+
+	frame, err := c.readFrame()
+	assert.NilError(t, err)
+	var resp rpc.Response
+	for _, v := range []interface{}{&resp.ServiceMethod, &resp.Seq, &resp.Error} {
+		val, _, err := frame.Read(cereal.Any)
+		assert.NilError(t, err)
+		assert.NilError(t, setBody(v, val))
+	}
+	assert.Equal(t, resp.Seq, uint64(1))
+	assert.Equal(t, resp.Error, "decoding limit exceeded: MaxLength of 26, got 1152921504606846976")
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bt/cereal"
+)
+
+// hexColumnBytes is the number of bytes of each value shown in the hex column.
+var hexColumnBytes = 16
+
+// input is a file or buffer being dumped.
+type input interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+func dumpCommand(args []string) error {
+	flags := flag.NewFlagSet("dump", flag.ContinueOnError)
+	hexColumn := flags.Bool("x", false, "show the leading bytes of each value in hex")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one file, got %d", flags.NArg())
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	return dump(out, f, *hexColumn)
+}
+
+// dump will write each top-level value of the input with its offset, length and type.
+func dump(w io.Writer, in input, hexColumn bool) error {
+	r := cereal.NewReader(in)
+	for {
+		offset, err := r.Offset()
+		if err != nil {
+			return err
+		}
+
+		// Read the type separately so unknown types are reported rather than read
+		t := make([]byte, 1)
+		if _, err = r.ReadRaw(t); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		dataType := cereal.DataType(t[0])
+		if dataType == cereal.Any || dataType.String() == "" {
+			return fmt.Errorf("unknown data type 0x%02x at offset %d", t[0], offset)
+		}
+
+		val, _, err := r.ReadGivenType(dataType)
+		if err != nil {
+			return fmt.Errorf("reading %s at offset %d: %v", dataType, offset, err)
+		}
+		end, err := r.Offset()
+		if err != nil {
+			return err
+		}
+
+		// Write columns
+		prefix := fmt.Sprintf("%08x  %8d  %-7s  ", offset, end-offset, dataType)
+		if hexColumn {
+			raw := make([]byte, end-offset)
+			if len(raw) > hexColumnBytes {
+				raw = raw[:hexColumnBytes]
+			}
+			if _, err = in.ReadAt(raw, int64(offset)); err != nil {
+				return err
+			}
+			column := hex.EncodeToString(raw)
+			if end-offset > uint64(hexColumnBytes) {
+				column += "..."
+			}
+			prefix += fmt.Sprintf("%-*s  ", hexColumnBytes*2+3, column)
+		}
+
+		lines := render(val)
+		indent := strings.Repeat(" ", len(prefix))
+		for i, line := range lines {
+			if i == 0 {
+				line = prefix + line
+			} else {
+				line = indent + line
+			}
+			if _, err = fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// render will return the lines of the value, with nested values indented.
+func render(val interface{}) []string {
+	switch v := val.(type) {
+	case string:
+		return []string{fmt.Sprintf("%q", v)}
+	case []byte:
+		return []string{fmt.Sprintf("%x", v)}
+	case []string:
+		if len(v) == 0 {
+			return []string{"[]"}
+		}
+		lines := []string{"["}
+		for _, s := range v {
+			lines = append(lines, fmt.Sprintf("  %q", s))
+		}
+		return append(lines, "]")
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return []string{"{}"}
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		lines := []string{"{"}
+		for _, k := range keys {
+			nested := render(v[k])
+			lines = append(lines, fmt.Sprintf("  %q: %s %s", k, typeName(v[k]), nested[0]))
+			for _, line := range nested[1:] {
+				lines = append(lines, "  "+line)
+			}
+		}
+		return append(lines, "}")
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// typeName returns the name of the data type of a value nested in a KeyValueMap.
+func typeName(val interface{}) string {
+	var t cereal.DataType
+	switch val.(type) {
+	case bool:
+		t = cereal.Boolean
+	case int64:
+		t = cereal.Integer
+	case uint64:
+		t = cereal.UnsignedInteger
+	case float64:
+		t = cereal.Float
+	case byte:
+		t = cereal.Byte
+	case []byte:
+		t = cereal.Bytes
+	case string:
+		t = cereal.String
+	case []string:
+		t = cereal.StringSlice
+	case map[string]interface{}:
+		t = cereal.KeyValueMap
+	default:
+		return fmt.Sprintf("%T", val)
+	}
+	return t.String()
+}
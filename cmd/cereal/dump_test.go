@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bt/cereal"
+	"gotest.tools/assert"
+)
+
+func TestDump(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := cereal.NewWriterFromBuffer(buf)
+	for _, v := range []interface{}{
+		"hello",
+		int64(-3),
+		map[string]interface{}{"name": "x", "tags": []string{"a", "b"}, "inner": map[string]interface{}{"ok": true}},
+		[]string{},
+	} {
+		_, _, err := w.Write(v)
+		assert.NilError(t, err)
+	}
+
+	out := new(bytes.Buffer)
+	assert.NilError(t, dump(out, bytes.NewReader(buf.Bytes()), false))
+	expected := []string{
+		`00000000         7  string   "hello"`,
+		`00000007         2  int      -3`,
+		`00000009        34  kvmap    {`,
+		`                               "inner": kvmap {`,
+		`                                 "ok": bool true`,
+		`                               }`,
+		`                               "name": string "x"`,
+		`                               "tags": strings [`,
+		`                                 "a"`,
+		`                                 "b"`,
+		`                               ]`,
+		`                             }`,
+		`0000002b         2  strings  []`,
+		``,
+	}
+	assert.Equal(t, out.String(), strings.Join(expected, "\n"))
+
+	out.Reset()
+	assert.NilError(t, dump(out, bytes.NewReader(buf.Bytes()), true))
+	lines := strings.Split(out.String(), "\n")
+	assert.Equal(t, lines[0], `00000000         7  string   070568656c6c6f                       "hello"`)
+	assert.Equal(t, lines[1], `00000007         2  int      0205                                 -3`)
+}
+
+func TestDump_UnknownType(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := cereal.NewWriterFromBuffer(buf)
+	_, _, err := w.Write("ok")
+	assert.NilError(t, err)
+	buf.WriteByte(0x7f)
+
+	out := new(bytes.Buffer)
+	err = dump(out, bytes.NewReader(buf.Bytes()), false)
+	assert.Error(t, err, "unknown data type 0x7f at offset 4")
+	assert.Equal(t, out.String(), "00000000         4  string   \"ok\"\n")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/bt/cereal"
+)
+
+// numberPolicies are the values of the fromjson -numbers flag.
+var numberPolicies = map[string]cereal.NumberPolicy{
+	"infer":    cereal.InferNumbers,
+	"unsigned": cereal.UnsignedNumbers,
+	"float":    cereal.FloatNumbers,
+}
+
+func jsonCommand(args []string) error {
+	flags := flag.NewFlagSet("json", flag.ContinueOnError)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one file, got %d", flags.NArg())
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	out := bufio.NewWriter(os.Stdout)
+	if err = cereal.ToJSON(cereal.NewReader(f), out); err != nil {
+		out.Flush()
+		return err
+	}
+	return out.Flush()
+}
+
+func fromJSONCommand(args []string) error {
+	flags := flag.NewFlagSet("fromjson", flag.ContinueOnError)
+	numbers := flags.String("numbers", "infer", "data type of numbers: infer, unsigned or float")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return fmt.Errorf("expected input and output files, got %d arguments", flags.NArg())
+	}
+	policy, ok := numberPolicies[*numbers]
+	if !ok {
+		return fmt.Errorf("unknown number policy '%s'", *numbers)
+	}
+
+	in, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	w := cereal.NewWriter(out)
+	if err = cereal.FromJSON(bufio.NewReader(in), w, policy); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
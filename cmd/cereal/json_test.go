@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/bt/cereal"
+	"gotest.tools/assert"
+)
+
+func TestFromJSONCommand(t *testing.T) {
+	assert.Equal(t, numberPolicies["unsigned"], cereal.UnsignedNumbers)
+
+	// Arguments are checked before any file is opened or created
+	assert.Error(t, fromJSONCommand([]string{"-numbers", "decimal", "in.json", "out"}), "unknown number policy 'decimal'")
+	assert.Error(t, fromJSONCommand([]string{"in.json"}), "expected input and output files, got 1 arguments")
+	assert.ErrorContains(t, fromJSONCommand([]string{"missing.json", "out"}), "no such file")
+}
@@ -0,0 +1,47 @@
+// Command cereal inspects cereal encoded files.
+//
+// Usage:
+//
+//	cereal dump [-x] file
+//	cereal json file
+//	cereal fromjson [-numbers infer|unsigned|float] input.json output
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// commands are the subcommands by name.
+var commands = map[string]func(args []string) error{
+	"dump":     dumpCommand,
+	"json":     jsonCommand,
+	"fromjson": fromJSONCommand,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: cereal <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	fmt.Fprintln(os.Stderr, "  dump [-x] file                 print each value with its offset, length and type")
+	fmt.Fprintln(os.Stderr, "  json file                      print each value as a line of JSON")
+	fmt.Fprintln(os.Stderr, "  fromjson [-numbers p] in out   write the JSON values of in to out")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "cereal: unknown command '%s'\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "cereal %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
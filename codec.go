@@ -0,0 +1,221 @@
+package cereal
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/pierrec/lz4"
+)
+
+// Codec compresses and decompresses the blocks of a compressed section.
+type Codec interface {
+	// ID returns the byte identifying the codec in the header of a compressed section.
+	ID() byte
+
+	// CompressBlock appends the compressed form of src to dst and returns the result.
+	CompressBlock(dst, src []byte) ([]byte, error)
+
+	// DecompressBlock decompresses src into dst, which is sized to the uncompressed length.
+	DecompressBlock(dst, src []byte) error
+}
+
+// Codec IDs used in the header of a compressed section.
+const (
+	UncompressedCodecID byte = iota
+	LZ4CodecID
+	DeflateCodecID
+	GzipCodecID
+)
+
+var (
+	// Uncompressed stores blocks as-is.
+	Uncompressed Codec = uncompressedCodec{}
+
+	// LZ4 compresses blocks using the LZ4 block format.
+	LZ4 Codec = &lz4Codec{}
+
+	// Deflate compresses blocks into raw DEFLATE streams.
+	Deflate Codec = &deflateCodec{}
+
+	// Gzip compresses blocks into gzip streams.
+	Gzip Codec = &gzipCodec{}
+)
+
+var codecs = map[byte]Codec{}
+
+func init() {
+	RegisterCodec(Uncompressed)
+	RegisterCodec(LZ4)
+	RegisterCodec(Deflate)
+	RegisterCodec(Gzip)
+}
+
+// RegisterCodec makes a codec available to readers of compressed sections. It panics if a codec with the same ID
+// has already been registered.
+func RegisterCodec(codec Codec) {
+	if _, ok := codecs[codec.ID()]; ok {
+		panic(fmt.Errorf("codec '%d' already registered", codec.ID()))
+	}
+	codecs[codec.ID()] = codec
+}
+
+type uncompressedCodec struct{}
+
+func (uncompressedCodec) ID() byte {
+	return UncompressedCodecID
+}
+
+func (uncompressedCodec) CompressBlock(dst, src []byte) ([]byte, error) {
+	return append(dst, src...), nil
+}
+
+func (uncompressedCodec) DecompressBlock(dst, src []byte) error {
+	if len(src) != len(dst) {
+		return fmt.Errorf("corrupt uncompressed block: expected %d bytes, got %d", len(dst), len(src))
+	}
+	copy(dst, src)
+	return nil
+}
+
+// lz4Codec keeps a pool of hash tables so blocks can be compressed concurrently.
+type lz4Codec struct {
+	hashTables sync.Pool
+}
+
+func (c *lz4Codec) ID() byte {
+	return LZ4CodecID
+}
+
+func (c *lz4Codec) CompressBlock(dst, src []byte) ([]byte, error) {
+	bound := lz4.CompressBlockBound(len(src))
+	if cap(dst)-len(dst) < bound {
+		grown := make([]byte, len(dst), len(dst)+bound)
+		copy(grown, dst)
+		dst = grown
+	}
+
+	hashTable, ok := c.hashTables.Get().([]int)
+	if !ok {
+		hashTable = make([]int, lz4HashTableSize)
+	}
+	defer c.hashTables.Put(hashTable)
+
+	n, err := lz4.CompressBlock(src, dst[len(dst):len(dst)+bound], hashTable)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		// Incompressible, the caller will store the block as-is
+		return append(dst, src...), nil
+	}
+	return dst[:len(dst)+n], nil
+}
+
+func (c *lz4Codec) DecompressBlock(dst, src []byte) error {
+	n, err := lz4.UncompressBlock(src, dst)
+	if err != nil {
+		return err
+	}
+	if n != len(dst) {
+		return fmt.Errorf("corrupt compressed block: expected %d bytes, got %d", len(dst), n)
+	}
+	return nil
+}
+
+type deflateCodec struct {
+	writers sync.Pool
+	readers sync.Pool
+}
+
+func (c *deflateCodec) ID() byte {
+	return DeflateCodecID
+}
+
+func (c *deflateCodec) CompressBlock(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	fw, ok := c.writers.Get().(*flate.Writer)
+	if ok {
+		fw.Reset(buf)
+	} else {
+		fw, _ = flate.NewWriter(buf, flate.DefaultCompression)
+	}
+	defer c.writers.Put(fw)
+
+	if _, err := fw.Write(src); err != nil {
+		return nil, err
+	}
+	if err := fw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *deflateCodec) DecompressBlock(dst, src []byte) error {
+	fr, ok := c.readers.Get().(io.ReadCloser)
+	if ok {
+		fr.(flate.Resetter).Reset(bytes.NewReader(src), nil)
+	} else {
+		fr = flate.NewReader(bytes.NewReader(src))
+	}
+	defer c.readers.Put(fr)
+
+	return readBlock(fr, dst)
+}
+
+type gzipCodec struct {
+	writers sync.Pool
+	readers sync.Pool
+}
+
+func (c *gzipCodec) ID() byte {
+	return GzipCodecID
+}
+
+func (c *gzipCodec) CompressBlock(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	gw, ok := c.writers.Get().(*gzip.Writer)
+	if ok {
+		gw.Reset(buf)
+	} else {
+		gw = gzip.NewWriter(buf)
+	}
+	defer c.writers.Put(gw)
+
+	if _, err := gw.Write(src); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *gzipCodec) DecompressBlock(dst, src []byte) (err error) {
+	gr, ok := c.readers.Get().(*gzip.Reader)
+	if ok {
+		err = gr.Reset(bytes.NewReader(src))
+	} else {
+		gr, err = gzip.NewReader(bytes.NewReader(src))
+	}
+	if err != nil {
+		return err
+	}
+	defer c.readers.Put(gr)
+
+	return readBlock(gr, dst)
+}
+
+// readBlock will read exactly len(dst) bytes from the stream and check that nothing follows them.
+func readBlock(r io.Reader, dst []byte) error {
+	if _, err := io.ReadFull(r, dst); err != nil {
+		return fmt.Errorf("corrupt compressed block: %v", err)
+	}
+	if n, _ := r.Read(make([]byte, 1)); n != 0 {
+		return fmt.Errorf("corrupt compressed block: more than %d bytes", len(dst))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package cereal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestCodec_RoundTrip(t *testing.T) {
+	tests := map[string]Codec{
+		"uncompressed": Uncompressed,
+		"lz4":          LZ4,
+		"deflate":      Deflate,
+		"gzip":         Gzip,
+	}
+
+	for name, codec := range tests {
+		for dataName, data := range compressTestData() {
+			t.Run(name+"/"+dataName, func(t *testing.T) {
+				buf := new(bytes.Buffer)
+				w := NewWriterFromBuffer(buf)
+
+				cw := w.BeginCompressed(codec)
+				_, err := cw.Write(data)
+				assert.NilError(t, err)
+				_, _, err = w.Write(uint64(42))
+				assert.NilError(t, err)
+				assert.NilError(t, cw.Close())
+				assert.Equal(t, buf.Bytes()[0], codec.ID())
+
+				r := NewReaderFromBuffer(buf.Bytes())
+				section := r.OpenCompressed()
+				got := make([]byte, len(data))
+				_, err = io.ReadFull(section, got)
+				assert.NilError(t, err)
+				assert.Assert(t, bytes.Equal(got, data))
+
+				val, _, err := r.Read(UnsignedInteger)
+				assert.NilError(t, err)
+				assert.Equal(t, val, uint64(42))
+			})
+		}
+	}
+}
+
+func TestCodec_Compresses(t *testing.T) {
+	data := compressTestData()["many blocks"]
+	for _, codec := range []Codec{LZ4, Deflate, Gzip} {
+		buf := new(bytes.Buffer)
+		cw := NewWriterFromBuffer(buf).BeginCompressed(codec)
+		_, err := cw.Write(data)
+		assert.NilError(t, err)
+		assert.NilError(t, cw.Close())
+		assert.Assert(t, buf.Len() < len(data)/4, "codec %d wrote %d bytes", codec.ID(), buf.Len())
+	}
+}
+
+func TestCodec_ConcurrentWriters(t *testing.T) {
+	for _, codec := range []Codec{LZ4, Deflate, Gzip} {
+		const writers = 8
+		var wg sync.WaitGroup
+		errs := make([]error, writers)
+
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				// Compressible data which differs between writers
+				words := make([]byte, 4*lz4BlockSize)
+				rnd := rand.New(rand.NewSource(int64(i)))
+				for j := range words {
+					words[j] = byte('a' + rnd.Intn(4))
+				}
+
+				buf := new(bytes.Buffer)
+				cw := NewWriterFromBuffer(buf).BeginCompressed(codec)
+				if _, err := cw.Write(words); err != nil {
+					errs[i] = err
+					return
+				}
+				if err := cw.Close(); err != nil {
+					errs[i] = err
+					return
+				}
+
+				got := make([]byte, len(words))
+				if _, err := io.ReadFull(NewReaderFromBuffer(buf.Bytes()).OpenCompressed(), got); err != nil {
+					errs[i] = err
+					return
+				}
+				if !bytes.Equal(got, words) {
+					errs[i] = fmt.Errorf("writer %d: decompressed data does not match", i)
+				}
+			}(i)
+		}
+
+		wg.Wait()
+		for _, err := range errs {
+			assert.NilError(t, err)
+		}
+	}
+}
+
+func TestCodec_Unknown(t *testing.T) {
+	r := NewReaderFromBuffer([]byte{0xff, 0x01, 0x01, 0x00, 0x00})
+	_, err := r.ReadCompressedBlock(make([]byte, lz4BlockSize))
+	assert.Error(t, err, "unknown compression codec '255'")
+}
+
+func TestCodec_RegisterDuplicate(t *testing.T) {
+	defer func() {
+		assert.Assert(t, recover() != nil)
+	}()
+	RegisterCodec(LZ4)
+}
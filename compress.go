@@ -0,0 +1,299 @@
+package cereal
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A compressed section starts with the ID of the codec used to compress it, followed by a sequence of blocks, each
+// holding at most lz4BlockSize bytes of input:
+//
+//	uvarint  uncompressed length
+//	uvarint  stored length
+//	[]byte   stored data
+//
+// If the stored length equals the uncompressed length then the block could not be compressed and the
+// data is stored as-is, otherwise the stored data is a block compressed by the section's codec. The
+// section ends with a single block that has an uncompressed length of zero and no stored length.
+
+// compressBlock will compress src with the codec and return the data to store for the block.
+func compressBlock(codec Codec, dst, src []byte) ([]byte, error) {
+	stored, err := codec.CompressBlock(dst[:0], src)
+	if err != nil {
+		return nil, err
+	}
+
+	// Incompressible data is stored as-is
+	if len(stored) >= len(src) {
+		return src, nil
+	}
+	return stored, nil
+}
+
+// decompressBlock will decompress the stored data of a block into dst, which must be the uncompressed length.
+func decompressBlock(codec Codec, dst, stored []byte) error {
+	if len(stored) == len(dst) {
+		copy(dst, stored)
+		return nil
+	}
+	return codec.DecompressBlock(dst, stored)
+}
+
+// compressedWriter buffers written bytes and writes them as blocks of a compressed section.
+type compressedWriter struct {
+	w      *Writer
+	out    *HashWriter
+	codec  Codec
+	buf    []byte
+	zbuf   []byte
+	err    error
+	closed bool
+
+	// Blocks being compressed in parallel by the workers, in the order they must be written
+	workers  int
+	jobs     chan *compressJob
+	wg       sync.WaitGroup
+	inflight []*compressJob
+	free     [][]byte
+}
+
+// compressJob is a block being compressed by a worker.
+type compressJob struct {
+	src    []byte
+	stored []byte
+	err    error
+	done   chan struct{}
+}
+
+// BeginCompressed will start a compressed section using the codec. Until the returned writer is closed, raw bytes
+// written to it and values written with Write are compressed into the section. Offsets returned while the section
+// is open are relative to the start of the uncompressed section.
+func (w *Writer) BeginCompressed(codec Codec) io.WriteCloser {
+	cw := &compressedWriter{
+		w:       w,
+		out:     w.w,
+		codec:   codec,
+		buf:     make([]byte, 0, lz4BlockSize),
+		workers: w.compressionWorkers,
+	}
+
+	// Write section header
+	cw.err = cw.out.WriteByte(codec.ID())
+
+	w.w = NewHashWriter(cw)
+	w.sections++
+	return cw
+}
+
+func (cw *compressedWriter) Write(p []byte) (n int, err error) {
+	if cw.closed {
+		return 0, fmt.Errorf("write to closed compressed section")
+	}
+	if cw.err != nil {
+		return 0, cw.err
+	}
+
+	for len(p) > 0 {
+		nn := copy(cw.buf[len(cw.buf):cap(cw.buf)], p)
+		cw.buf = cw.buf[:len(cw.buf)+nn]
+		p = p[nn:]
+		n += nn
+
+		if len(cw.buf) == cap(cw.buf) {
+			if err = cw.flush(); err != nil {
+				cw.err = err
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (cw *compressedWriter) flush() (err error) {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+
+	if cw.workers <= 1 {
+		stored, err := compressBlock(cw.codec, cw.zbuf, cw.buf)
+		if err != nil {
+			return err
+		}
+		if len(stored) < len(cw.buf) {
+			cw.zbuf = stored
+		}
+		if err = cw.writeBlock(cw.buf, stored); err != nil {
+			return err
+		}
+		cw.buf = cw.buf[:0]
+		return nil
+	}
+
+	// Hand the block to a worker and continue buffering into a spare buffer
+	if cw.jobs == nil {
+		cw.startWorkers()
+	}
+	job := &compressJob{src: cw.buf, done: make(chan struct{})}
+	cw.inflight = append(cw.inflight, job)
+	cw.jobs <- job
+
+	if n := len(cw.free); n > 0 {
+		cw.buf = cw.free[n-1]
+		cw.free = cw.free[:n-1]
+	} else {
+		cw.buf = make([]byte, 0, lz4BlockSize)
+	}
+
+	// Once every worker is busy wait for the oldest block
+	for len(cw.inflight) >= cw.workers {
+		if err = cw.writeNext(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startWorkers will start the workers which compress blocks handed to them by flush.
+func (cw *compressedWriter) startWorkers() {
+	cw.jobs = make(chan *compressJob, cw.workers)
+	cw.wg.Add(cw.workers)
+	for i := 0; i < cw.workers; i++ {
+		go func() {
+			defer cw.wg.Done()
+			for job := range cw.jobs {
+				job.stored, job.err = compressBlock(cw.codec, nil, job.src)
+				close(job.done)
+			}
+		}()
+	}
+}
+
+// stopWorkers will wait for the workers to finish the blocks handed to them and exit.
+func (cw *compressedWriter) stopWorkers() {
+	if cw.jobs == nil {
+		return
+	}
+	close(cw.jobs)
+	cw.wg.Wait()
+	cw.jobs = nil
+}
+
+// writeNext will wait for the oldest block being compressed and write it.
+func (cw *compressedWriter) writeNext() error {
+	job := cw.inflight[0]
+	cw.inflight = cw.inflight[1:]
+
+	<-job.done
+	if job.err != nil {
+		return job.err
+	}
+	if err := cw.writeBlock(job.src, job.stored); err != nil {
+		return err
+	}
+	cw.free = append(cw.free, job.src[:0])
+	return nil
+}
+
+func (cw *compressedWriter) writeBlock(src, stored []byte) (err error) {
+	// Write uncompressed and stored lengths
+	if err = writeUvarint(cw.out, uint64(len(src))); err != nil {
+		return err
+	}
+	if err = writeUvarint(cw.out, uint64(len(stored))); err != nil {
+		return err
+	}
+
+	// Write block
+	_, err = cw.out.Write(stored)
+	return err
+}
+
+// Close will write any buffered data and the end of the section, then return the writer to uncompressed output.
+func (cw *compressedWriter) Close() error {
+	if cw.closed {
+		return nil
+	}
+	cw.closed = true
+	cw.w.w = cw.out
+	cw.w.sections--
+
+	// Blocks may still be compressing from their buffers when writing fails
+	defer cw.stopWorkers()
+
+	if cw.err != nil {
+		return cw.err
+	}
+	if err := cw.flush(); err != nil {
+		return err
+	}
+	for len(cw.inflight) > 0 {
+		if err := cw.writeNext(); err != nil {
+			return err
+		}
+	}
+
+	// Write end of section
+	return writeUvarint(cw.out, 0)
+}
+
+// compressedReader decompresses the blocks of a compressed section.
+type compressedReader struct {
+	r     *Reader
+	block []byte
+
+	// Blocks read ahead and being decompressed in parallel, in the order they were read
+	workers  int
+	pending  []*decompressJob
+	lastRead bool
+}
+
+// decompressJob is a block being decompressed by a worker.
+type decompressJob struct {
+	out  []byte
+	err  error
+	done chan struct{}
+}
+
+// OpenCompressed will start reading a compressed section. Raw bytes can be read from the returned reader and values
+// can be decoded with Read; once the end of the section is reached the Reader continues with the data that follows.
+func (r *Reader) OpenCompressed() io.Reader {
+	return r.openSection(&compressedReader{
+		r:       &Reader{r: r.r},
+		block:   make([]byte, lz4BlockSize),
+		workers: r.decompressionWorkers,
+	})
+}
+
+// next will return the next decompressed block.
+func (cr *compressedReader) next() ([]byte, error) {
+	if cr.workers <= 1 {
+		n, err := cr.r.ReadCompressedBlock(cr.block)
+		return cr.block[:n], err
+	}
+
+	// Read ahead so that every worker has a block to decompress
+	for !cr.lastRead && len(cr.pending) < cr.workers {
+		job := &decompressJob{done: make(chan struct{})}
+		cr.pending = append(cr.pending, job)
+
+		codec, rawLen, stored, err := cr.r.readStoredBlock()
+		if err != nil {
+			job.err = err
+			cr.lastRead = true
+			close(job.done)
+			break
+		}
+
+		job.out = make([]byte, rawLen)
+		go func() {
+			job.err = decompressBlock(codec, job.out, stored)
+			close(job.done)
+		}()
+	}
+
+	job := cr.pending[0]
+	cr.pending = cr.pending[1:]
+	<-job.done
+	return job.out, job.err
+}
@@ -0,0 +1,325 @@
+package cereal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func compressTestData() map[string][]byte {
+	random := make([]byte, 3*lz4BlockSize/2)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	return map[string][]byte{
+		"empty":          {},
+		"single byte":    {0x2a},
+		"short":          []byte("hello hello hello hello hello hello"),
+		"exact block":    bytes.Repeat([]byte{0x01, 0x02, 0x03, 0x04}, lz4BlockSize/4),
+		"block plus one": bytes.Repeat([]byte{0x07}, lz4BlockSize+1),
+		"many blocks":    bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 10000),
+		"incompressible": random,
+	}
+}
+
+func TestCompress_ReadCompressedBlock(t *testing.T) {
+	for name, data := range compressTestData() {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w := NewWriterFromBuffer(buf)
+
+			offset, length, err := w.WriteRawToLZ4Compress(data)
+			assert.NilError(t, err)
+			assert.Equal(t, offset, uint64(0))
+			assert.Equal(t, length, buf.Len())
+
+			// Trailing value must still be readable after the section
+			_, _, err = w.Write("after")
+			assert.NilError(t, err)
+
+			r := NewReaderFromBuffer(buf.Bytes())
+			var out bytes.Buffer
+			block := make([]byte, lz4BlockSize)
+			for {
+				n, err := r.ReadCompressedBlock(block)
+				if err == io.EOF {
+					break
+				}
+				assert.NilError(t, err)
+				out.Write(block[:n])
+			}
+			assert.Assert(t, bytes.Equal(out.Bytes(), data))
+
+			val, _, err := r.Read(String)
+			assert.NilError(t, err)
+			assert.Equal(t, val, "after")
+		})
+	}
+}
+
+func TestCompress_DecompressToFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cereal")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	for name, data := range compressTestData() {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Create(filepath.Join(dir, "compressed"))
+			assert.NilError(t, err)
+			w := NewWriter(f)
+			_, _, err = w.WriteRawToLZ4Compress(data)
+			assert.NilError(t, err)
+			assert.NilError(t, w.Close())
+
+			f, err = os.Open(filepath.Join(dir, "compressed"))
+			assert.NilError(t, err)
+			defer f.Close()
+
+			out := filepath.Join(dir, "decompressed")
+			assert.NilError(t, NewReader(f).DecompressToFile(out))
+
+			got, err := ioutil.ReadFile(out)
+			assert.NilError(t, err)
+			assert.Assert(t, bytes.Equal(got, data))
+		})
+	}
+}
+
+func TestCompress_CorruptBlock(t *testing.T) {
+	data := bytes.Repeat([]byte("abcdefgh"), 1024)
+	buf := new(bytes.Buffer)
+	_, _, err := NewWriterFromBuffer(buf).WriteRawToLZ4Compress(data)
+	assert.NilError(t, err)
+
+	// Truncate the section partway through the first block
+	r := NewReaderFromBuffer(buf.Bytes()[:buf.Len()/2])
+	_, err = r.ReadCompressedBlock(make([]byte, lz4BlockSize))
+	assert.Assert(t, err != nil)
+}
+
+func TestCompress_StreamValues(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+
+	_, _, err := w.Write("before")
+	assert.NilError(t, err)
+
+	values := make([]interface{}, 0, 20000)
+	for i := 0; i < cap(values)/2; i++ {
+		values = append(values, int64(i*7919))
+		values = append(values, map[string]interface{}{"id": uint64(i), "name": "value"})
+	}
+
+	cw := w.BeginCompressed(LZ4)
+	for i, v := range values {
+		offset, _, err := w.Write(v)
+		assert.NilError(t, err)
+		if i == 0 {
+			assert.Equal(t, offset, uint64(0))
+		}
+	}
+	assert.NilError(t, cw.Close())
+
+	_, _, err = w.Write("after")
+	assert.NilError(t, err)
+
+	r := NewReaderFromBuffer(buf.Bytes())
+	val, _, err := r.Read(String)
+	assert.NilError(t, err)
+	assert.Equal(t, val, "before")
+
+	r.OpenCompressed()
+	for _, v := range values {
+		val, _, err := r.Read(Any)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, val, v)
+	}
+
+	val, _, err = r.Read(String)
+	assert.NilError(t, err)
+	assert.Equal(t, val, "after")
+}
+
+func TestCompress_StreamRaw(t *testing.T) {
+	data := compressTestData()["many blocks"]
+
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	cw := w.BeginCompressed(LZ4)
+	for chunk := data; len(chunk) > 0; {
+		n := 1000
+		if n > len(chunk) {
+			n = len(chunk)
+		}
+		_, err := cw.Write(chunk[:n])
+		assert.NilError(t, err)
+		chunk = chunk[n:]
+	}
+	assert.NilError(t, cw.Close())
+	_, _, err := w.Write(true)
+	assert.NilError(t, err)
+
+	r := NewReaderFromBuffer(buf.Bytes())
+	got, err := ioutil.ReadAll(r.OpenCompressed())
+	assert.NilError(t, err)
+	assert.Assert(t, bytes.Equal(got, data))
+
+	val, _, err := r.Read(Boolean)
+	assert.NilError(t, err)
+	assert.Equal(t, val, true)
+}
+
+func TestCompress_Parallel(t *testing.T) {
+	data := compressTestData()["many blocks"]
+	for _, codec := range []Codec{LZ4, Deflate} {
+		sequential := new(bytes.Buffer)
+		cw := NewWriterFromBuffer(sequential).BeginCompressed(codec)
+		_, err := cw.Write(data)
+		assert.NilError(t, err)
+		assert.NilError(t, cw.Close())
+
+		for _, workers := range []int{2, 3, 8} {
+			t.Run(fmt.Sprintf("%d/%d", codec.ID(), workers), func(t *testing.T) {
+				parallel := new(bytes.Buffer)
+				w := NewWriterFromBuffer(parallel)
+				w.SetCompressionWorkers(workers)
+				cw := w.BeginCompressed(codec)
+				_, err := cw.Write(data)
+				assert.NilError(t, err)
+				assert.NilError(t, cw.Close())
+				_, _, err = w.Write("after")
+				assert.NilError(t, err)
+
+				// Blocks must be written in their original order
+				assert.Assert(t, bytes.HasPrefix(parallel.Bytes(), sequential.Bytes()))
+
+				r := NewReaderFromBuffer(parallel.Bytes())
+				r.SetDecompressionWorkers(workers)
+				got, err := ioutil.ReadAll(r.OpenCompressed())
+				assert.NilError(t, err)
+				assert.Assert(t, bytes.Equal(got, data))
+
+				val, _, err := r.Read(String)
+				assert.NilError(t, err)
+				assert.Equal(t, val, "after")
+			})
+		}
+	}
+}
+
+func TestCompress_ParallelValues(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	w.SetCompressionWorkers(4)
+
+	cw := w.BeginCompressed(LZ4)
+	for i := 0; i < 50000; i++ {
+		_, _, err := w.Write([]string{"value", fmt.Sprint(i)})
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, cw.Close())
+
+	r := NewReaderFromBuffer(buf.Bytes())
+	r.SetDecompressionWorkers(4)
+	r.OpenCompressed()
+	for i := 0; i < 50000; i++ {
+		val, _, err := r.Read(StringSlice)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, val, []string{"value", fmt.Sprint(i)})
+	}
+}
+
+// failingCodec fails to compress blocks after the first.
+type failingCodec struct {
+	Codec
+	calls int32
+}
+
+func (c *failingCodec) CompressBlock(dst, src []byte) ([]byte, error) {
+	if atomic.AddInt32(&c.calls, 1) > 1 {
+		return nil, fmt.Errorf("compression failed")
+	}
+	return c.Codec.CompressBlock(dst, src)
+}
+
+func TestCompress_ParallelClose(t *testing.T) {
+	data := compressTestData()["many blocks"]
+	before := runtime.NumGoroutine()
+
+	w := NewWriterFromBuffer(new(bytes.Buffer))
+	w.SetCompressionWorkers(4)
+	cw := w.BeginCompressed(&failingCodec{Codec: LZ4})
+	for err := error(nil); err == nil; {
+		_, err = cw.Write(data)
+	}
+	assert.Error(t, cw.Close(), "compression failed")
+
+	// Workers have exited once Close returns, even when compression failed
+	assert.Equal(t, runtime.NumGoroutine(), before)
+}
+
+func TestCompress_ParallelCorrupt(t *testing.T) {
+	data := compressTestData()["many blocks"]
+	buf := new(bytes.Buffer)
+	_, _, err := NewWriterFromBuffer(buf).WriteRawToLZ4Compress(data)
+	assert.NilError(t, err)
+
+	r := NewReaderFromBuffer(buf.Bytes()[:buf.Len()/2])
+	r.SetDecompressionWorkers(4)
+	_, err = ioutil.ReadAll(r.OpenCompressed())
+	assert.Assert(t, err != nil)
+}
+
+func TestCompress_BlockBoundary(t *testing.T) {
+	values := []interface{}{uint64(math.MaxUint64), int64(math.MinInt64), 3.25, "boundary"}
+
+	for name, begin := range map[string]func(w *Writer) (io.WriteCloser, int){
+		"compressed": func(w *Writer) (io.WriteCloser, int) {
+			return w.BeginCompressed(LZ4), lz4BlockSize
+		},
+		"encrypted": func(w *Writer) (io.WriteCloser, int) {
+			ew, err := w.BeginEncrypted(testKey)
+			assert.NilError(t, err)
+			return ew, encryptedChunkSize
+		},
+	} {
+		for _, v := range values {
+			for before := 1; before <= 9; before++ {
+				t.Run(fmt.Sprintf("%s %v %d", name, v, before), func(t *testing.T) {
+					buf := new(bytes.Buffer)
+					w := NewWriterFromBuffer(buf)
+					section, blockSize := begin(w)
+					padding := make([]byte, blockSize-before)
+					_, err := w.WriteRaw(padding)
+					assert.NilError(t, err)
+					_, _, err = w.Write(v)
+					assert.NilError(t, err)
+					assert.NilError(t, section.Close())
+
+					r := NewReaderFromBuffer(buf.Bytes())
+					var content io.Reader
+					if name == "compressed" {
+						content = r.OpenCompressed()
+					} else {
+						content, err = r.OpenEncrypted(testKey)
+						assert.NilError(t, err)
+					}
+					_, err = io.ReadFull(content, padding)
+					assert.NilError(t, err)
+					val, _, err := r.Read(Any)
+					assert.NilError(t, err)
+					assert.Equal(t, val, v)
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package cereal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// An encrypted section starts with a random salt, followed by a sequence of chunks, each holding at most
+// encryptedChunkSize bytes of plaintext:
+//
+//	byte     1 if this is the final chunk, otherwise 0
+//	uvarint  sealed length
+//	[]byte   chunk sealed with AES-GCM
+//
+// Chunks are sealed with a key derived from the caller's key and the salt using HKDF-SHA256, so each section has its
+// own key and nonces are never reused across sections. The nonce of each chunk is the big-endian chunk index followed
+// by the final chunk flag, so chunks cannot be reordered, dropped or marked final without failing authentication. The
+// section ends with the final chunk, which may be empty.
+
+var (
+	encryptedChunkSize = 64 << 10
+	nonceSize          = 12
+	sectionSaltSize    = 32
+	sectionKeyInfo     = []byte("cereal encrypted section")
+)
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sectionGCM returns the AEAD of the section with the salt, using a key derived from the key and the salt.
+func sectionGCM(key, salt []byte) (cipher.AEAD, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, aes.KeySizeError(len(key))
+	}
+	return newGCM(hkdfSHA256(key, salt, sectionKeyInfo, len(key)))
+}
+
+// hkdfSHA256 derives a key of at most 32 bytes from the secret as described in RFC 5869.
+func hkdfSHA256(secret, salt, info []byte, n int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write(info)
+	expand.Write([]byte{1})
+	return expand.Sum(nil)[:n]
+}
+
+// chunkNonce returns the nonce for the chunk with the given index.
+func chunkNonce(index uint32, final bool) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint32(nonce[nonceSize-5:], index)
+	if final {
+		nonce[nonceSize-1] = 1
+	}
+	return nonce
+}
+
+// encryptedWriter buffers written bytes and writes them as chunks of an encrypted section.
+type encryptedWriter struct {
+	w      *Writer
+	out    *HashWriter
+	aead   cipher.AEAD
+	index  uint32
+	buf    []byte
+	sealed []byte
+	err    error
+	closed bool
+}
+
+// BeginEncrypted will start a section encrypted with AES-GCM using the key, which must be 16, 24 or 32 bytes long.
+// Until the returned writer is closed, raw bytes written to it and values written with Write are encrypted into the
+// section. Offsets returned while the section is open are relative to the start of the plaintext. Compressed
+// sections may be started inside an encrypted section to compress the data before it is encrypted.
+func (w *Writer) BeginEncrypted(key []byte) (io.WriteCloser, error) {
+	salt := make([]byte, sectionSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	aead, err := sectionGCM(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	ew := &encryptedWriter{
+		w:    w,
+		out:  w.w,
+		aead: aead,
+		buf:  make([]byte, 0, encryptedChunkSize),
+	}
+
+	// Write section header
+	if _, err = ew.out.Write(salt); err != nil {
+		return nil, err
+	}
+
+	w.w = NewHashWriter(ew)
+	w.sections++
+	return ew, nil
+}
+
+func (ew *encryptedWriter) Write(p []byte) (n int, err error) {
+	if ew.closed {
+		return 0, fmt.Errorf("write to closed encrypted section")
+	}
+	if ew.err != nil {
+		return 0, ew.err
+	}
+
+	for len(p) > 0 {
+		nn := copy(ew.buf[len(ew.buf):cap(ew.buf)], p)
+		ew.buf = ew.buf[:len(ew.buf)+nn]
+		p = p[nn:]
+		n += nn
+
+		if len(ew.buf) == cap(ew.buf) {
+			if err = ew.writeChunk(false); err != nil {
+				ew.err = err
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (ew *encryptedWriter) writeChunk(final bool) (err error) {
+	if ew.index == ^uint32(0) {
+		return fmt.Errorf("encrypted section too large")
+	}
+	ew.sealed = ew.aead.Seal(ew.sealed[:0], chunkNonce(ew.index, final), ew.buf, nil)
+	ew.index++
+
+	// Write final flag and sealed length
+	flag := byte(0)
+	if final {
+		flag = 1
+	}
+	if err = ew.out.WriteByte(flag); err != nil {
+		return err
+	}
+	if err = writeUvarint(ew.out, uint64(len(ew.sealed))); err != nil {
+		return err
+	}
+
+	// Write chunk
+	if _, err = ew.out.Write(ew.sealed); err != nil {
+		return err
+	}
+	ew.buf = ew.buf[:0]
+	return nil
+}
+
+// Close will write the final chunk, then return the writer to unencrypted output.
+func (ew *encryptedWriter) Close() error {
+	if ew.closed {
+		return nil
+	}
+	ew.closed = true
+	ew.w.w = ew.out
+	ew.w.sections--
+
+	if ew.err != nil {
+		return ew.err
+	}
+	return ew.writeChunk(true)
+}
+
+// encryptedReader decrypts the chunks of an encrypted section.
+type encryptedReader struct {
+	r     *Reader
+	aead  cipher.AEAD
+	index uint32
+	chunk []byte
+	ended bool
+}
+
+// OpenEncrypted will start reading a section encrypted with the key. Raw bytes can be read from the returned reader
+// and values can be decoded with Read; once the end of the section is reached the Reader continues with the data
+// that follows. An error is returned from reads if the section has been tampered with or truncated.
+func (r *Reader) OpenEncrypted(key []byte) (io.Reader, error) {
+	// Read section header
+	er := &encryptedReader{r: &Reader{r: r.r}}
+	salt := make([]byte, sectionSaltSize)
+	if err := er.r.readBytes(salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := sectionGCM(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	er.aead = aead
+	return r.openSection(er), nil
+}
+
+// next will return the next decrypted chunk.
+func (er *encryptedReader) next() ([]byte, error) {
+	if er.ended {
+		return nil, io.EOF
+	}
+
+	flag, err := er.r.readByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if flag > 1 {
+		return nil, fmt.Errorf("corrupt encrypted chunk: invalid final flag '%d'", flag)
+	}
+
+	len, _, err := er.r.readUint()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if len > uint64(encryptedChunkSize+er.aead.Overhead()) {
+		return nil, fmt.Errorf("corrupt encrypted chunk: sealed length %d exceeds chunk size", len)
+	}
+
+	sealed := make([]byte, len)
+	if err = er.r.readBytes(sealed); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	er.chunk, err = er.aead.Open(er.chunk[:0], chunkNonce(er.index, flag == 1), sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("encrypted chunk %d: %v", er.index, err)
+	}
+	er.index++
+	er.ended = flag == 1
+	return er.chunk, nil
+}
+
+// unexpectedEOF converts io.EOF into io.ErrUnexpectedEOF, for data which must be followed by more data.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+package cereal
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func writeEncrypted(t *testing.T, data []byte, compress bool) []byte {
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+
+	ew, err := w.BeginEncrypted(testKey)
+	assert.NilError(t, err)
+	if compress {
+		cw := w.BeginCompressed(LZ4)
+		_, err = cw.Write(data)
+		assert.NilError(t, err)
+		assert.NilError(t, cw.Close())
+	} else {
+		_, err = ew.Write(data)
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, ew.Close())
+
+	_, _, err = w.Write("after")
+	assert.NilError(t, err)
+	return buf.Bytes()
+}
+
+func TestEncrypt_RoundTrip(t *testing.T) {
+	for name, data := range compressTestData() {
+		t.Run(name, func(t *testing.T) {
+			buf := writeEncrypted(t, data, false)
+			assert.Assert(t, !bytes.Contains(buf, []byte("hello hello")))
+
+			r := NewReaderFromBuffer(buf)
+			section, err := r.OpenEncrypted(testKey)
+			assert.NilError(t, err)
+			got, err := ioutil.ReadAll(section)
+			assert.NilError(t, err)
+			assert.Assert(t, bytes.Equal(got, data))
+
+			val, _, err := r.Read(String)
+			assert.NilError(t, err)
+			assert.Equal(t, val, "after")
+		})
+	}
+}
+
+func TestEncrypt_Compressed(t *testing.T) {
+	data := compressTestData()["many blocks"]
+	buf := writeEncrypted(t, data, true)
+	assert.Assert(t, len(buf) < len(data)/4)
+
+	r := NewReaderFromBuffer(buf)
+	_, err := r.OpenEncrypted(testKey)
+	assert.NilError(t, err)
+	got, err := ioutil.ReadAll(r.OpenCompressed())
+	assert.NilError(t, err)
+	assert.Assert(t, bytes.Equal(got, data))
+
+	val, _, err := r.Read(String)
+	assert.NilError(t, err)
+	assert.Equal(t, val, "after")
+}
+
+func TestEncrypt_Values(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	ew, err := w.BeginEncrypted(testKey)
+	assert.NilError(t, err)
+	for i := 0; i < 10000; i++ {
+		_, _, err = w.Write(map[string]interface{}{"i": int64(i)})
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, ew.Close())
+
+	r := NewReaderFromBuffer(buf.Bytes())
+	_, err = r.OpenEncrypted(testKey)
+	assert.NilError(t, err)
+	for i := 0; i < 10000; i++ {
+		val, _, err := r.Read(KeyValueMap)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, val, map[string]interface{}{"i": int64(i)})
+	}
+}
+
+func TestEncrypt_Rejects(t *testing.T) {
+	data := bytes.Repeat([]byte{0x01, 0x02}, encryptedChunkSize)
+	buf := writeEncrypted(t, data, false)
+	chunkLen := 1 + 3 + encryptedChunkSize + 16
+	header := sectionSaltSize
+
+	swapped := append([]byte{}, buf...)
+	copy(swapped[header:], buf[header+chunkLen:header+2*chunkLen])
+	copy(swapped[header+chunkLen:], buf[header:header+chunkLen])
+
+	tampered := append([]byte{}, buf...)
+	tampered[header+100] ^= 0x01
+
+	tests := map[string]struct {
+		buf []byte
+		key []byte
+		err string
+	}{
+		"wrong key":       {buf: buf, key: []byte("fedcba9876543210fedcba9876543210"), err: "encrypted chunk 0: cipher: message authentication failed"},
+		"tampered":        {buf: tampered, key: testKey, err: "encrypted chunk 0: cipher: message authentication failed"},
+		"reordered":       {buf: swapped, key: testKey, err: "encrypted chunk 0: cipher: message authentication failed"},
+		"truncated":       {buf: buf[:header+2*chunkLen], key: testKey, err: io.ErrUnexpectedEOF.Error()},
+		"missing final":   {buf: buf[:header+chunkLen], key: testKey, err: io.ErrUnexpectedEOF.Error()},
+		"partial chunk":   {buf: buf[:header+chunkLen/2], key: testKey, err: io.ErrUnexpectedEOF.Error()},
+		"invalid key len": {buf: buf, key: []byte("short"), err: "crypto/aes: invalid key size 5"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := NewReaderFromBuffer(test.buf)
+			section, err := r.OpenEncrypted(test.key)
+			if err == nil {
+				_, err = ioutil.ReadAll(section)
+			}
+			assert.Error(t, err, test.err)
+		})
+	}
+}
+
+func TestEncrypt_SectionKeys(t *testing.T) {
+	// RFC 5869 test case 1, truncated to the first 32 bytes of output
+	secret := bytes.Repeat([]byte{0x0b}, 22)
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	assert.Equal(t, hex.EncodeToString(hkdfSHA256(secret, salt, info, 32)),
+		"3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf")
+
+	// Each section has its own salt and so its own key
+	data := []byte("same plaintext")
+	first := writeEncrypted(t, data, false)
+	second := writeEncrypted(t, data, false)
+	assert.Assert(t, !bytes.Equal(first[:sectionSaltSize], second[:sectionSaltSize]))
+	assert.Assert(t, !bytes.Equal(first[sectionSaltSize:], second[sectionSaltSize:]))
+
+	// Changing the salt changes the key
+	first[0] ^= 0x01
+	section, err := NewReaderFromBuffer(first).OpenEncrypted(testKey)
+	assert.NilError(t, err)
+	_, err = ioutil.ReadAll(section)
+	assert.Error(t, err, "encrypted chunk 0: cipher: message authentication failed")
+}
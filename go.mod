@@ -1,5 +1,7 @@
 module github.com/bt/cereal
 
+go 1.18
+
 require (
 	github.com/google/go-cmp v0.2.0 // indirect
 	github.com/pierrec/lz4 v2.0.5+incompatible
@@ -2,6 +2,7 @@ package cereal
 
 import (
 	"bufio"
+	"hash"
 	"hash/crc32"
 	"io"
 )
@@ -10,6 +11,9 @@ type HashWriter struct {
 	w   *bufio.Writer
 	crc uint32
 	n   int
+
+	// Optional hash of the written content, used for signing
+	sum hash.Hash
 }
 
 // Write writes the provided bytes to the wrapped writer, recalculates the checksum and counts the bytes.
@@ -17,6 +21,9 @@ func (h *HashWriter) Write(p []byte) (n int, err error) {
 	defer h.w.Flush()
 	n, err = h.w.Write(p)
 	h.crc = crc32.Update(h.crc, crc32.IEEETable, p[:n])
+	if h.sum != nil {
+		h.sum.Write(p[:n])
+	}
 	h.n += n
 	return n, err
 }
@@ -0,0 +1,291 @@
+package cereal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// An indexed file ends with an index block followed by a footer, before any signature trailer. The footer is
+// extended when a Bloom filter is written, see bloom.go:
+//
+//	uvarint  number of entries
+//	uvarint  offset of value, for each entry
+//	uvarint  key length, for each entry
+//	[]byte   key, for each entry
+//	[8]byte  big-endian offset of the index block
+//	[4]byte  indexMagic
+
+var indexMagic = []byte("CIDX")
+
+// indexFooterLen is the length of the footer pointing at the index block.
+var indexFooterLen = 8 + len(indexMagic)
+
+// ErrNoIndex is returned when the content does not end with an index.
+var ErrNoIndex = errors.New("content has no index")
+
+// IndexEntry is the offset of a top-level value and the key it was written with, if any.
+type IndexEntry struct {
+	Key    string
+	Offset uint64
+}
+
+// SetIndexed will toggle whether the offset of each top-level value is recorded and written as an index when the
+// writer is closed. Values written inside compressed or encrypted sections are not indexed.
+func (w *Writer) SetIndexed(b bool) {
+	w.indexed = b
+}
+
+// WriteKeyed will write the value and record it in the index under the key.
+func (w *Writer) WriteKeyed(key string, data interface{}) (offset uint64, length int, err error) {
+	if !w.indexed {
+		return 0, 0, fmt.Errorf("cannot write keyed value, index is not enabled")
+	}
+	if w.sections != 0 {
+		return 0, 0, fmt.Errorf("cannot write keyed value inside a section")
+	}
+
+	offset, length, err = w.Write(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	w.index[len(w.index)-1].Key = key
+	return offset, length, nil
+}
+
+// writeIndex will write the filter block if enabled, then the index block and footer.
+func (w *Writer) writeIndex() error {
+	filterOffset := w.w.Count()
+	if w.filterBitsPerKey > 0 {
+		if err := w.writeFilter(); err != nil {
+			return err
+		}
+	}
+	offset := w.w.Count()
+
+	// Write entries
+	if err := w.appendUvarint(uint64(len(w.index))); err != nil {
+		return err
+	}
+	for _, e := range w.index {
+		if err := w.appendUvarint(e.Offset); err != nil {
+			return err
+		}
+		if err := w.appendBytes([]byte(e.Key)); err != nil {
+			return err
+		}
+	}
+
+	// Write footer
+	var footer []byte
+	if w.filterBitsPerKey > 0 {
+		footer = make([]byte, filterFooterLen)
+		binary.BigEndian.PutUint64(footer, filterOffset)
+		binary.BigEndian.PutUint64(footer[8:], offset)
+		copy(footer[16:], filterMagic)
+	} else {
+		footer = make([]byte, indexFooterLen)
+		binary.BigEndian.PutUint64(footer, offset)
+		copy(footer[8:], indexMagic)
+	}
+	_, err := w.w.Write(footer)
+	return err
+}
+
+// Index will return the entries of the index, in the order the values were written.
+func (r *Reader) Index() ([]IndexEntry, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.index != nil {
+		return r.index, nil
+	}
+
+	offset, err := r.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	index, err := r.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = r.r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	r.index = index
+	r.indexKeys = make(map[string]int, len(index))
+	for i := len(index) - 1; i >= 0; i-- {
+		if index[i].Key != "" {
+			r.indexKeys[index[i].Key] = i
+		}
+	}
+	return r.index, nil
+}
+
+// indexFooter is the location of the index block and filter block, if any.
+type indexFooter struct {
+	index    uint64
+	filter   uint64
+	filtered bool
+
+	// Offset of the footer
+	end uint64
+}
+
+func (r *Reader) readIndexFooter() (indexFooter, error) {
+	end, err := r.contentEnd()
+	if err != nil {
+		return indexFooter{}, err
+	}
+	if end < int64(indexFooterLen) {
+		return indexFooter{}, ErrNoIndex
+	}
+
+	// Read magic
+	magic := make([]byte, len(indexMagic))
+	if _, err = r.r.Seek(end-int64(len(magic)), io.SeekStart); err != nil {
+		return indexFooter{}, err
+	}
+	if err = r.readBytes(magic); err != nil {
+		return indexFooter{}, err
+	}
+
+	var footer indexFooter
+	switch string(magic) {
+	case string(indexMagic):
+		footer.end = uint64(end) - uint64(indexFooterLen)
+	case string(filterMagic):
+		if end < int64(filterFooterLen) {
+			return indexFooter{}, ErrNoIndex
+		}
+		footer.end = uint64(end) - uint64(filterFooterLen)
+		footer.filtered = true
+	default:
+		return indexFooter{}, ErrNoIndex
+	}
+
+	// Read offsets
+	buf := make([]byte, int(uint64(end)-footer.end)-len(magic))
+	if _, err = r.r.Seek(int64(footer.end), io.SeekStart); err != nil {
+		return indexFooter{}, err
+	}
+	if err = r.readBytes(buf); err != nil {
+		return indexFooter{}, err
+	}
+	footer.index = binary.BigEndian.Uint64(buf[len(buf)-8:])
+	if footer.filtered {
+		footer.filter = binary.BigEndian.Uint64(buf)
+		if footer.filter > footer.index {
+			return indexFooter{}, fmt.Errorf("corrupt index: filter offset %d beyond index", footer.filter)
+		}
+	}
+	if footer.index >= footer.end {
+		return indexFooter{}, fmt.Errorf("corrupt index: offset %d beyond end of content", footer.index)
+	}
+	return footer, nil
+}
+
+func (r *Reader) readIndex() ([]IndexEntry, error) {
+	footer, err := r.readIndexFooter()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read entries
+	if _, err = r.r.Seek(int64(footer.index), io.SeekStart); err != nil {
+		return nil, err
+	}
+	len, _, err := r.readUint()
+	if err != nil {
+		return nil, err
+	}
+
+	// Each entry takes at least 2 bytes, an offset and the length of its key
+	offset, err := r.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	if offset > int64(footer.end) || len > (footer.end-uint64(offset))/2 {
+		return nil, fmt.Errorf("corrupt index: %d entries", len)
+	}
+	index := make([]IndexEntry, len)
+	for i := range index {
+		if index[i].Offset, _, err = r.readUint(); err != nil {
+			return nil, err
+		}
+		if index[i].Key, _, err = r.readString(); err != nil {
+			return nil, err
+		}
+	}
+	return index, nil
+}
+
+// contentEnd returns the length of the content, excluding any signature trailer.
+func (r *Reader) contentEnd() (int64, error) {
+	end, err := r.size()
+	if err != nil {
+		return 0, err
+	}
+
+	trailer := int64(len(signatureMagic) + 1)
+	if end < trailer {
+		return end, nil
+	}
+	buf := make([]byte, trailer)
+	if _, err = r.r.Seek(end-trailer, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err = r.readBytes(buf); err != nil {
+		return 0, err
+	}
+	if string(buf[1:]) != string(signatureMagic) || signatureSize(buf[0]) < 0 {
+		return end, nil
+	}
+	return end - trailer - int64(signatureSize(buf[0])), nil
+}
+
+// size returns the length of the input.
+func (r *Reader) size() (int64, error) {
+	offset, err := r.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	end, err := r.r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = r.r.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return end, nil
+}
+
+// ReadAt will seek to the nth indexed value and read it. Reading continues from the end of the value.
+func (r *Reader) ReadAt(i int) (interface{}, DataType, error) {
+	index, err := r.Index()
+	if err != nil {
+		return nil, 0, err
+	}
+	if i < 0 || i >= len(index) {
+		return nil, 0, fmt.Errorf("index entry %d out of range [0, %d)", i, len(index))
+	}
+
+	if err = r.SeekTo(index[i].Offset); err != nil {
+		return nil, 0, err
+	}
+	return r.Read(Any)
+}
+
+// ReadKey will seek to the first value indexed under the key and read it. Reading continues from the end of the value.
+func (r *Reader) ReadKey(key string) (interface{}, DataType, error) {
+	if _, err := r.Index(); err != nil {
+		return nil, 0, err
+	}
+	i, ok := r.indexKeys[key]
+	if !ok {
+		return nil, 0, fmt.Errorf("key '%s' not found in index", key)
+	}
+	return r.ReadAt(i)
+}
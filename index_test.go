@@ -0,0 +1,134 @@
+package cereal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestIndex_ReadAt(t *testing.T) {
+	values := []interface{}{
+		"first",
+		map[string]interface{}{"nested": "not indexed", "n": int64(1)},
+		uint64(3),
+		[]string{"a", "b"},
+	}
+
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	w.SetIndexed(true)
+	assert.NilError(t, w.SetSigningKey([]byte("secret")))
+
+	var expected []IndexEntry
+	for _, v := range values {
+		offset, _, err := w.Write(v)
+		assert.NilError(t, err)
+		expected = append(expected, IndexEntry{Offset: offset})
+	}
+
+	// Values inside sections are not indexed
+	cw := w.BeginCompressed(LZ4)
+	_, _, err := w.Write("compressed")
+	assert.NilError(t, err)
+	assert.NilError(t, cw.Close())
+
+	offset, _, err := w.WriteKeyed("answer", int64(42))
+	assert.NilError(t, err)
+	expected = append(expected, IndexEntry{Key: "answer", Offset: offset})
+	values = append(values, int64(42))
+	assert.NilError(t, w.Close())
+
+	r := NewReaderFromBuffer(buf.Bytes())
+	assert.NilError(t, r.Verify([]byte("secret")))
+	index, err := r.Index()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, index, expected)
+
+	for i := len(values) - 1; i >= 0; i-- {
+		val, _, err := r.ReadAt(i)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, val, values[i])
+	}
+
+	val, _, err := r.ReadKey("answer")
+	assert.NilError(t, err)
+	assert.Equal(t, val, int64(42))
+
+	_, _, err = r.ReadKey("question")
+	assert.Error(t, err, "key 'question' not found in index")
+	_, _, err = r.ReadAt(len(values))
+	assert.Error(t, err, "index entry 5 out of range [0, 5)")
+}
+
+func TestIndex_ReadSeeker(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	w.SetIndexed(true)
+	for _, key := range []string{"a", "b", "c"} {
+		_, _, err := w.WriteKeyed(key, key+key)
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, w.Close())
+
+	r := NewReader(bytes.NewReader(buf.Bytes()))
+	val, _, err := r.ReadKey("b")
+	assert.NilError(t, err)
+	assert.Equal(t, val, "bb")
+
+	// Reading continues after the value
+	val, _, err = r.Read(String)
+	assert.NilError(t, err)
+	assert.Equal(t, val, "cc")
+}
+
+func TestIndex_CorruptCount(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	w.SetIndexed(true)
+	_, _, err := w.WriteKeyed("key", strings.Repeat("v", 1000))
+	assert.NilError(t, err)
+	assert.NilError(t, w.Close())
+
+	// Claim more entries than the index region can hold, though fewer than the bytes before it
+	content := buf.Bytes()
+	offset := bytes.LastIndex(content, []byte{1, 0, 3, 'k', 'e', 'y'})
+	assert.Assert(t, offset > 0)
+	content[offset] = 0x7f
+
+	_, err = NewReaderFromBuffer(content).Index()
+	assert.Error(t, err, "corrupt index: 127 entries")
+}
+
+func TestIndex_NoIndex(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	_, _, err := w.Write("unindexed")
+	assert.NilError(t, err)
+	_, _, err = w.WriteKeyed("key", "value")
+	assert.Error(t, err, "cannot write keyed value, index is not enabled")
+
+	_, err = NewReaderFromBuffer(buf.Bytes()).Index()
+	assert.Equal(t, err, ErrNoIndex)
+}
+
+func TestIndex_CloseOpenSection(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	w.SetIndexed(true)
+	_, _, err := w.WriteKeyed("key", "value")
+	assert.NilError(t, err)
+	cw := w.BeginCompressed(LZ4)
+	_, _, err = w.Write("compressed")
+	assert.NilError(t, err)
+
+	// The index is not written into the open section
+	assert.Error(t, w.Close(), "cannot close writer while a section is open")
+	assert.NilError(t, cw.Close())
+	assert.NilError(t, w.Close())
+
+	val, _, err := NewReaderFromBuffer(buf.Bytes()).ReadKey("key")
+	assert.NilError(t, err)
+	assert.Equal(t, val, "value")
+}
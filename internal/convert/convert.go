@@ -0,0 +1,38 @@
+// Package convert converts the numbers read by a cereal.Reader to other numeric types.
+package convert
+
+import (
+	"reflect"
+)
+
+// Number will set the target to the number read if it fits, reporting false if it is not a number or does not fit.
+// Integers are read as int64, uint64 or byte and floats as float64.
+func Number(target reflect.Value, val interface{}) bool {
+	v := reflect.ValueOf(val)
+	overflow := true
+	switch target.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v.Kind() {
+		case reflect.Int64:
+			overflow = target.OverflowInt(v.Int())
+		case reflect.Uint64, reflect.Uint8:
+			overflow = v.Uint() > 1<<63-1 || target.OverflowInt(int64(v.Uint()))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch v.Kind() {
+		case reflect.Uint64, reflect.Uint8:
+			overflow = target.OverflowUint(v.Uint())
+		case reflect.Int64:
+			overflow = v.Int() < 0 || target.OverflowUint(uint64(v.Int()))
+		}
+	case reflect.Float32, reflect.Float64:
+		if v.Kind() == reflect.Float64 {
+			overflow = target.OverflowFloat(v.Float())
+		}
+	}
+	if overflow {
+		return false
+	}
+	target.Set(v.Convert(target.Type()))
+	return true
+}
@@ -0,0 +1,99 @@
+// Package transcode holds the helpers shared by the decoders which transcode other wire formats to cereal.
+package transcode
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/bt/cereal"
+)
+
+// DefaultMaxDepth is the deepest nesting a decoder reads unless set otherwise.
+const DefaultMaxDepth = 100
+
+// Depth tracks the nesting of the value being decoded, where a top-level collection has a depth of 1, like
+// cereal.ReaderOptions.MaxDepth.
+type Depth struct {
+	// Max is the deepest nesting allowed. Zero is unlimited.
+	Max int
+
+	depth int
+}
+
+// Enter will check the depth of a nested value being decoded. Callers must call Leave once it has been decoded.
+func (d *Depth) Enter() error {
+	d.depth++
+	if d.Max > 0 && d.depth > d.Max {
+		return &cereal.LimitExceededError{Limit: "MaxDepth", Value: uint64(d.depth), Max: d.Max}
+	}
+	return nil
+}
+
+// Leave will end the nested value entered last.
+func (d *Depth) Leave() {
+	d.depth--
+}
+
+// ReadN will read n bytes, growing the buffer as data arrives so corrupt lengths do not allocate up front.
+func ReadN(r io.Reader, n uint64) ([]byte, error) {
+	if n > math.MaxInt64 {
+		return nil, fmt.Errorf("invalid length %d", n)
+	}
+	buf := new(bytes.Buffer)
+	if _, err := io.CopyN(buf, r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	b := buf.Bytes()
+	if b == nil {
+		b = []byte{}
+	}
+	return b, nil
+}
+
+// ToCereal will convert a decoded value to a value which can be written by a cereal.Writer. Null is the name of nil
+// values in the wire format, for errors.
+func ToCereal(v interface{}, null string) (interface{}, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, fmt.Errorf("cannot transcode %s to cereal", null)
+	case time.Time:
+		return v.UnixNano(), nil
+	case *big.Int:
+		if v.IsInt64() {
+			return v.Int64(), nil
+		}
+		if v.IsUint64() {
+			return v.Uint64(), nil
+		}
+		return nil, fmt.Errorf("cannot transcode bignum %s to cereal, out of range", v)
+	case []interface{}:
+		s := make([]string, len(v))
+		for i, e := range v {
+			str, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("cannot transcode array with non-string element '%v' to cereal", e)
+			}
+			s[i] = str
+		}
+		return s, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			val, err := ToCereal(e, null)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = val
+		}
+		return m, nil
+	default:
+		return v, nil
+	}
+}
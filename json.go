@@ -0,0 +1,118 @@
+package cereal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// NumberPolicy decides the data type JSON numbers are written as by FromJSON.
+type NumberPolicy int
+
+const (
+	// InferNumbers writes integers as Integer, integers too large for Integer as UnsignedInteger and other numbers as
+	// Float.
+	InferNumbers NumberPolicy = iota
+	// UnsignedNumbers writes non-negative integers as UnsignedInteger, negative integers as Integer and other numbers
+	// as Float.
+	UnsignedNumbers
+	// FloatNumbers writes all numbers as Float.
+	FloatNumbers
+)
+
+// ToJSON will read values until the end of the reader and write each as a line of JSON. KeyValueMaps are written as
+// objects, StringSlices as arrays and Bytes as base64 strings.
+func ToJSON(r *Reader, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for {
+		val, dataType, err := r.Read(Any)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err = enc.Encode(val); err != nil {
+			return fmt.Errorf("cannot convert '%s' value to JSON: %v", dataType, err)
+		}
+	}
+}
+
+// FromJSON will read a stream of JSON values and write each to the writer. Objects are written as KeyValueMaps and
+// arrays of strings as StringSlices. Numbers are written according to the number policy.
+func FromJSON(r io.Reader, w *Writer, numbers NumberPolicy) error {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	for {
+		var v interface{}
+		if err := dec.Decode(&v); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		val, err := fromJSONValue(v, numbers)
+		if err != nil {
+			return err
+		}
+		if _, _, err = w.Write(val); err != nil {
+			return err
+		}
+	}
+}
+
+// fromJSONValue will convert a decoded JSON value to a value which can be written.
+func fromJSONValue(v interface{}, numbers NumberPolicy) (interface{}, error) {
+	switch v := v.(type) {
+	case bool, string:
+		return v, nil
+	case json.Number:
+		return fromJSONNumber(v, numbers)
+	case []interface{}:
+		s := make([]string, len(v))
+		for i, e := range v {
+			str, ok := e.(string)
+			if !ok {
+				return nil, fmt.Errorf("cannot convert JSON array with non-string element '%v'", e)
+			}
+			s[i] = str
+		}
+		return s, nil
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			val, err := fromJSONValue(e, numbers)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = val
+		}
+		return m, nil
+	case nil:
+		return nil, fmt.Errorf("cannot convert JSON null")
+	default:
+		return nil, fmt.Errorf("cannot convert JSON value '%v'", v)
+	}
+}
+
+func fromJSONNumber(n json.Number, numbers NumberPolicy) (interface{}, error) {
+	s := n.String()
+	if numbers == FloatNumbers || strings.ContainsAny(s, ".eE") {
+		return n.Float64()
+	}
+
+	if numbers == UnsignedNumbers && !strings.HasPrefix(s, "-") {
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return u, nil
+		}
+	} else if i, err := n.Int64(); err == nil {
+		return i, nil
+	} else if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return u, nil
+	}
+
+	// Integers out of range of both types lose precision
+	return n.Float64()
+}
@@ -0,0 +1,104 @@
+package cereal
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestToJSON(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	for _, v := range []interface{}{
+		"<hello>",
+		int64(-3),
+		uint64(math.MaxUint64),
+		1.5,
+		true,
+		[]byte("raw"),
+		[]string{"a", "b"},
+		map[string]interface{}{"n": int64(1), "tags": []string{}, "inner": map[string]interface{}{"ok": false}},
+	} {
+		_, _, err := w.Write(v)
+		assert.NilError(t, err)
+	}
+
+	out := new(bytes.Buffer)
+	assert.NilError(t, ToJSON(NewReaderFromBuffer(buf.Bytes()), out))
+	expected := []string{
+		`"<hello>"`,
+		`-3`,
+		`18446744073709551615`,
+		`1.5`,
+		`true`,
+		`"cmF3"`,
+		`["a","b"]`,
+		`{"inner":{"ok":false},"n":1,"tags":[]}`,
+		``,
+	}
+	assert.Equal(t, out.String(), strings.Join(expected, "\n"))
+}
+
+func TestFromJSON(t *testing.T) {
+	input := `{"name": "x", "count": 3, "tags": ["a"], "nested": {"ratio": 0.5, "big": 18446744073709551615}}
+		-7 2 1e3 "str" false []`
+
+	tests := []struct {
+		policy   NumberPolicy
+		expected []interface{}
+	}{
+		{
+			InferNumbers,
+			[]interface{}{
+				map[string]interface{}{"name": "x", "count": int64(3), "tags": []string{"a"},
+					"nested": map[string]interface{}{"ratio": 0.5, "big": uint64(math.MaxUint64)}},
+				int64(-7), int64(2), 1000.0, "str", false, []string{},
+			},
+		},
+		{
+			UnsignedNumbers,
+			[]interface{}{
+				map[string]interface{}{"name": "x", "count": uint64(3), "tags": []string{"a"},
+					"nested": map[string]interface{}{"ratio": 0.5, "big": uint64(math.MaxUint64)}},
+				int64(-7), uint64(2), 1000.0, "str", false, []string{},
+			},
+		},
+		{
+			FloatNumbers,
+			[]interface{}{
+				map[string]interface{}{"name": "x", "count": 3.0, "tags": []string{"a"},
+					"nested": map[string]interface{}{"ratio": 0.5, "big": float64(math.MaxUint64)}},
+				-7.0, 2.0, 1000.0, "str", false, []string{},
+			},
+		},
+	}
+	for _, tt := range tests {
+		buf := new(bytes.Buffer)
+		w := NewWriterFromBuffer(buf)
+		assert.NilError(t, FromJSON(strings.NewReader(input), w, tt.policy))
+
+		r := NewReaderFromBuffer(buf.Bytes())
+		for _, expected := range tt.expected {
+			val, _, err := r.Read(Any)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, val, expected)
+		}
+		_, _, err := r.Read(Any)
+		assert.Assert(t, err != nil)
+	}
+}
+
+func TestFromJSON_Errors(t *testing.T) {
+	tests := map[string]string{
+		`null`:            "cannot convert JSON null",
+		`{"a": [1, "b"]}`: "cannot convert JSON array with non-string element '1'",
+		`{"a": `:          "unexpected EOF",
+	}
+	for input, expected := range tests {
+		w := NewWriterFromBuffer(new(bytes.Buffer))
+		assert.Error(t, FromJSON(strings.NewReader(input), w, InferNumbers), expected)
+	}
+}
@@ -0,0 +1,100 @@
+package cereal
+
+import (
+	"fmt"
+)
+
+// Estimated bytes allocated for each entry of a StringSlice and KeyValueMap, excluding the strings and values.
+const (
+	stringSliceEntrySize = 16
+	keyValueMapEntrySize = 48
+)
+
+// maxPreallocatedEntries is the most entries of a StringSlice allocated before they are read.
+const maxPreallocatedEntries = 1024
+
+// readChunkSize is the most bytes of a String or Bytes value allocated before they are read from a stream.
+const readChunkSize = 64 << 10
+
+// ReaderOptions limits what a Reader allocates, so corrupt or malicious input returns a LimitExceededError instead of
+// exhausting memory. Zero fields are unlimited.
+type ReaderOptions struct {
+	// MaxLength is the longest String or Bytes value, after decompression.
+	MaxLength int
+
+	// MaxEntries is the most entries of a StringSlice or KeyValueMap.
+	MaxEntries int
+
+	// MaxDepth is the deepest nesting of KeyValueMaps, where a top-level map has a depth of 1.
+	MaxDepth int
+
+	// MaxAllocation is the most bytes allocated for a single top-level value, counting the length of strings and
+	// bytes and an estimate for each collection entry.
+	MaxAllocation int
+}
+
+// LimitExceededError is returned when reading a value would exceed a limit of the ReaderOptions.
+type LimitExceededError struct {
+	// Limit is the name of the ReaderOptions field which was exceeded.
+	Limit string
+
+	// Value is the length, count, depth or allocation which exceeded the limit.
+	Value uint64
+
+	// Max is the value of the limit.
+	Max int
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("decoding limit exceeded: %s of %d, got %d", e.Limit, e.Max, e.Value)
+}
+
+// SetOptions will set the limits of values read afterwards.
+func (r *Reader) SetOptions(opts ReaderOptions) {
+	r.opts = opts
+}
+
+// checkLength will check and account for the allocation of a String or Bytes value of n bytes.
+func (r *Reader) checkLength(n uint64) error {
+	if r.opts.MaxLength > 0 && n > uint64(r.opts.MaxLength) {
+		return &LimitExceededError{Limit: "MaxLength", Value: n, Max: r.opts.MaxLength}
+	}
+	return r.allocate(n)
+}
+
+// checkEntries will check and account for the allocation of a collection of n entries of size bytes.
+func (r *Reader) checkEntries(n uint64, size uint64) error {
+	if r.opts.MaxEntries > 0 && n > uint64(r.opts.MaxEntries) {
+		return &LimitExceededError{Limit: "MaxEntries", Value: n, Max: r.opts.MaxEntries}
+	}
+	if n > ^uint64(0)/size {
+		return r.allocate(^uint64(0))
+	}
+	return r.allocate(n * size)
+}
+
+// enter will check the depth of a KeyValueMap being read. Callers must call leave once the map has been read.
+func (r *Reader) enter() error {
+	r.depth++
+	if r.opts.MaxDepth > 0 && r.depth > r.opts.MaxDepth {
+		return &LimitExceededError{Limit: "MaxDepth", Value: uint64(r.depth), Max: r.opts.MaxDepth}
+	}
+	return nil
+}
+
+func (r *Reader) leave() {
+	r.depth--
+}
+
+// allocate will account for n bytes allocated for the value being read.
+func (r *Reader) allocate(n uint64) error {
+	if r.opts.MaxAllocation > 0 && n > uint64(r.opts.MaxAllocation)-r.allocated {
+		total := r.allocated + n
+		if total < n {
+			total = ^uint64(0)
+		}
+		return &LimitExceededError{Limit: "MaxAllocation", Value: total, Max: r.opts.MaxAllocation}
+	}
+	r.allocated += n
+	return nil
+}
@@ -0,0 +1,117 @@
+package cereal
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+// encodeLength will return the data type followed by a length prefix.
+func encodeLength(dataType DataType, n uint64) []byte {
+	buf := make([]byte, 1+binary.MaxVarintLen64)
+	buf[0] = byte(dataType)
+	return buf[:1+binary.PutUvarint(buf[1:], n)]
+}
+
+func TestReader_Limits(t *testing.T) {
+	nested := map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": "d"}}}
+
+	var compressed bytes.Buffer
+	w := NewWriterFromBuffer(&compressed)
+	w.SetValueCompression(LZ4, 16)
+	_, _, err := w.Write(strings.Repeat("a", 1000))
+	assert.NilError(t, err)
+
+	var encoded bytes.Buffer
+	w = NewWriterFromBuffer(&encoded)
+	_, _, err = w.Write(nested)
+	assert.NilError(t, err)
+
+	for _, test := range []struct {
+		name    string
+		opts    ReaderOptions
+		content []byte
+		limit   string
+	}{
+		{"string length", ReaderOptions{MaxLength: 1024}, encodeLength(String, 1<<60), "MaxLength"},
+		{"bytes length", ReaderOptions{MaxLength: 1024}, encodeLength(Bytes, 1<<60), "MaxLength"},
+		{"compressed length", ReaderOptions{MaxLength: 999}, compressed.Bytes(), "MaxLength"},
+		{"slice entries", ReaderOptions{MaxEntries: 1000}, encodeLength(StringSlice, 1<<60), "MaxEntries"},
+		{"map entries", ReaderOptions{MaxEntries: 1000}, encodeLength(KeyValueMap, 1<<60), "MaxEntries"},
+		{"depth", ReaderOptions{MaxDepth: 2}, encoded.Bytes(), "MaxDepth"},
+		{"allocation", ReaderOptions{MaxAllocation: 1 << 20}, encodeLength(StringSlice, 1<<60), "MaxAllocation"},
+		{"compressed allocation", ReaderOptions{MaxAllocation: 999}, compressed.Bytes(), "MaxAllocation"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			r := NewReaderFromBuffer(test.content)
+			r.SetOptions(test.opts)
+			_, _, err := r.Read(Any)
+			limitErr, ok := err.(*LimitExceededError)
+			assert.Assert(t, ok, "got %v", err)
+			assert.Equal(t, limitErr.Limit, test.limit)
+		})
+	}
+}
+
+func TestReader_LimitsWithin(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriterFromBuffer(&buf)
+	for _, v := range []interface{}{
+		strings.Repeat("a", 100),
+		[]string{"a", "b", "c"},
+		map[string]interface{}{"a": map[string]interface{}{"b": "c"}},
+		strings.Repeat("b", 100),
+	} {
+		_, _, err := w.Write(v)
+		assert.NilError(t, err)
+	}
+
+	// The allocation budget applies to each top-level value
+	r := NewReaderFromBuffer(buf.Bytes())
+	r.SetOptions(ReaderOptions{MaxLength: 100, MaxEntries: 3, MaxDepth: 2, MaxAllocation: 150})
+	for i := 0; i < 4; i++ {
+		_, _, err := r.Read(Any)
+		assert.NilError(t, err)
+	}
+}
+
+func TestReader_CorruptLengths(t *testing.T) {
+	for _, dataType := range []DataType{String, Bytes} {
+		for _, n := range []uint64{10, readChunkSize + 1, 1 << 60, 1<<64 - 1} {
+			content := append(encodeLength(dataType, n), "short"...)
+
+			// Lengths beyond the input fail without allocating them
+			for _, zeroCopy := range []bool{false, true} {
+				r := NewReaderFromBuffer(content)
+				r.SetZeroCopy(zeroCopy)
+				_, _, err := r.Read(Any)
+				assert.Equal(t, err, io.ErrUnexpectedEOF, "%s of %d", dataType, n)
+			}
+			_, _, err := NewReader(bytes.NewReader(content)).Read(Any)
+			assert.Equal(t, err, io.ErrUnexpectedEOF, "%s of %d", dataType, n)
+		}
+	}
+
+	// Values longer than a chunk are read whole from streams
+	value := strings.Repeat("x", 3*readChunkSize+1)
+	var buf bytes.Buffer
+	_, _, err := NewWriterFromBuffer(&buf).Write(value)
+	assert.NilError(t, err)
+	val, _, err := NewReader(bytes.NewReader(buf.Bytes())).Read(Any)
+	assert.NilError(t, err)
+	assert.Equal(t, val, value)
+}
+
+func TestReader_UnknownType(t *testing.T) {
+	_, _, err := NewReaderFromBuffer([]byte{0xff}).Read(Any)
+	assert.Error(t, err, "cannot read value, unknown data type '255'")
+}
+
+func TestLimitExceededError(t *testing.T) {
+	err := &LimitExceededError{Limit: "MaxLength", Value: 2048, Max: 1024}
+	assert.Error(t, err, "decoding limit exceeded: MaxLength of 1024, got 2048")
+}
@@ -0,0 +1,221 @@
+package cereal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Each message is sent as a frame:
+//
+//	[4]byte  big-endian length of the value
+//	byte     flags
+//	[]byte   value, inside a compressed section if frameCompressed is set
+
+// frameCompressed is the frame flag set when the value is compressed.
+const frameCompressed byte = 1 << 0
+
+// frameHeaderLen is the length of the frame header.
+var frameHeaderLen = 5
+
+// DefaultMaxFrameSize is the largest value a message connection sends or receives unless set otherwise.
+var DefaultMaxFrameSize = 16 << 20
+
+// MessageConn sends and receives values over a connection as length-prefixed frames. Send and Receive may be called
+// concurrently with each other.
+type MessageConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+
+	maxFrameSize         int
+	readerOpts           ReaderOptions
+	codec                Codec
+	compressionThreshold int
+	readTimeout          time.Duration
+	writeTimeout         time.Duration
+
+	readMu sync.Mutex
+	// Error returned by all receives after the stream can no longer be read
+	readErr error
+
+	writeMu sync.Mutex
+	buf     bytes.Buffer
+}
+
+// NewMessageConn will return a new message connection over the connection.
+func NewMessageConn(conn net.Conn) *MessageConn {
+	return &MessageConn{
+		conn:         conn,
+		r:            bufio.NewReader(conn),
+		w:            bufio.NewWriter(conn),
+		maxFrameSize: DefaultMaxFrameSize,
+	}
+}
+
+// SetMaxFrameSize will set the largest encoded value which is sent or received.
+func (c *MessageConn) SetMaxFrameSize(n int) {
+	c.maxFrameSize = n
+}
+
+// SetReaderOptions will set the limits of values received. Zero MaxLength and MaxAllocation default to the maximum
+// frame size, which may need raising to receive compressed values which are larger once decompressed.
+func (c *MessageConn) SetReaderOptions(opts ReaderOptions) {
+	c.readerOpts = opts
+}
+
+// SetCompression will compress values whose encoding is at least threshold bytes with the codec. A nil codec
+// disables compression. Receiving compressed values needs no setting.
+func (c *MessageConn) SetCompression(codec Codec, threshold int) {
+	c.codec = codec
+	c.compressionThreshold = threshold
+}
+
+// SetReadTimeout will set the time each Receive may wait for a value. Zero waits indefinitely.
+func (c *MessageConn) SetReadTimeout(d time.Duration) {
+	c.readTimeout = d
+}
+
+// SetWriteTimeout will set the time each Send may take to write a value. Zero waits indefinitely.
+func (c *MessageConn) SetWriteTimeout(d time.Duration) {
+	c.writeTimeout = d
+}
+
+// SetReadDeadline will set the deadline for receiving values. A read timeout replaces the deadline on each Receive.
+func (c *MessageConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline will set the deadline for sending values. A write timeout replaces the deadline on each Send.
+func (c *MessageConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// Send will write the value as a frame.
+func (c *MessageConn) Send(v interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	flags, err := c.encode(v)
+	if err != nil {
+		return err
+	}
+	if c.buf.Len() > c.maxFrameSize {
+		return fmt.Errorf("frame of %d bytes exceeds limit of %d bytes", c.buf.Len(), c.maxFrameSize)
+	}
+
+	if c.writeTimeout > 0 {
+		if err = c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+			return err
+		}
+	}
+	header := make([]byte, frameHeaderLen)
+	binary.BigEndian.PutUint32(header, uint32(c.buf.Len()))
+	header[4] = flags
+	if _, err = c.w.Write(header); err != nil {
+		return err
+	}
+	if _, err = c.w.Write(c.buf.Bytes()); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// encode will write the value to the frame buffer, compressing it if enabled, and return the frame flags.
+func (c *MessageConn) encode(v interface{}) (flags byte, err error) {
+	c.buf.Reset()
+	if _, _, err = NewWriterFromBuffer(&c.buf).Write(v); err != nil {
+		return 0, err
+	}
+	if c.codec == nil || c.buf.Len() < c.compressionThreshold {
+		return 0, nil
+	}
+
+	c.buf.Reset()
+	w := NewWriterFromBuffer(&c.buf)
+	cw := w.BeginCompressed(c.codec)
+	if _, _, err = w.Write(v); err != nil {
+		return 0, err
+	}
+	if err = cw.Close(); err != nil {
+		return 0, err
+	}
+	return frameCompressed, nil
+}
+
+// Receive will read the next value. Once a frame cannot be read, such as one exceeding the maximum frame size, all
+// later receives return the same error.
+func (c *MessageConn) Receive() (interface{}, DataType, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	if c.readErr != nil {
+		return nil, 0, c.readErr
+	}
+	if c.readTimeout > 0 {
+		if err := c.conn.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	frame, flags, err := c.readFrame()
+	if err != nil {
+		// Timeouts before any of the frame is read leave the stream intact
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() || frame != nil {
+			c.readErr = err
+		}
+		return nil, 0, err
+	}
+
+	opts := c.readerOpts
+	if opts.MaxLength == 0 {
+		opts.MaxLength = c.maxFrameSize
+	}
+	if opts.MaxAllocation == 0 {
+		opts.MaxAllocation = c.maxFrameSize
+	}
+	r := NewReaderFromBuffer(frame)
+	r.SetOptions(opts)
+	if flags&frameCompressed != 0 {
+		r.OpenCompressed()
+	}
+	return r.Read(Any)
+}
+
+// readFrame will read the next frame. A non-nil frame is returned if part of the frame was read before an error.
+func (c *MessageConn) readFrame() ([]byte, byte, error) {
+	if _, err := c.r.Peek(1); err != nil {
+		return nil, 0, err
+	}
+
+	header := make([]byte, frameHeaderLen)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		return header, 0, err
+	}
+	n := binary.BigEndian.Uint32(header)
+	if uint64(n) > uint64(c.maxFrameSize) {
+		return header, 0, fmt.Errorf("frame of %d bytes exceeds limit of %d bytes", n, c.maxFrameSize)
+	}
+	if flags := header[4]; flags&^frameCompressed != 0 {
+		return header, 0, fmt.Errorf("unknown frame flags 0x%02x", flags)
+	}
+
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(c.r, frame); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return header, 0, err
+	}
+	return frame, header[4], nil
+}
+
+// Close will close the connection.
+func (c *MessageConn) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,151 @@
+package cereal
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestMessageConn_SendReceive(t *testing.T) {
+	a, b := net.Pipe()
+	client, server := NewMessageConn(a), NewMessageConn(b)
+	defer client.Close()
+	client.SetCompression(LZ4, 64)
+
+	values := []interface{}{
+		"hello",
+		int64(-1),
+		map[string]interface{}{"body": strings.Repeat("compressible ", 100), "n": uint64(2)},
+		[]string{"a"},
+	}
+	go func() {
+		for _, v := range values {
+			if err := client.Send(v); err != nil {
+				panic(err)
+			}
+		}
+	}()
+
+	for _, expected := range values {
+		val, _, err := server.Receive()
+		assert.NilError(t, err)
+		assert.DeepEqual(t, val, expected)
+	}
+
+	// Receiving returns EOF once the peer hangs up
+	client.Close()
+	_, _, err := server.Receive()
+	assert.Equal(t, err, io.EOF)
+}
+
+func TestMessageConn_Compression(t *testing.T) {
+	a, b := net.Pipe()
+	client := NewMessageConn(a)
+	defer client.Close()
+	client.SetCompression(Deflate, 64)
+
+	value := strings.Repeat("compressible ", 100)
+	go client.Send(value)
+
+	// Read the raw frame
+	header := make([]byte, frameHeaderLen)
+	_, err := io.ReadFull(b, header)
+	assert.NilError(t, err)
+	assert.Equal(t, header[4], frameCompressed)
+	n := binary.BigEndian.Uint32(header)
+	assert.Assert(t, n < uint32(len(value)), n)
+
+	frame := make([]byte, n)
+	_, err = io.ReadFull(b, frame)
+	assert.NilError(t, err)
+	r := NewReaderFromBuffer(frame)
+	r.OpenCompressed()
+	val, _, err := r.Read(String)
+	assert.NilError(t, err)
+	assert.Equal(t, val, value)
+}
+
+func TestMessageConn_MaxFrameSize(t *testing.T) {
+	a, b := net.Pipe()
+	client, server := NewMessageConn(a), NewMessageConn(b)
+	defer client.Close()
+	client.SetMaxFrameSize(16)
+	server.SetMaxFrameSize(16)
+
+	err := client.Send(strings.Repeat("x", 16))
+	assert.Error(t, err, "frame of 18 bytes exceeds limit of 16 bytes")
+
+	// Frames over the limit from the peer break the stream
+	client.SetMaxFrameSize(DefaultMaxFrameSize)
+	go client.Send(strings.Repeat("x", 16))
+	_, _, err = server.Receive()
+	assert.Error(t, err, "frame of 18 bytes exceeds limit of 16 bytes")
+	_, _, err = server.Receive()
+	assert.Error(t, err, "frame of 18 bytes exceeds limit of 16 bytes")
+
+	assert.ErrorContains(t, client.Send(func() {}), "cannot write value, unknown data type")
+}
+
+func TestMessageConn_ReaderOptions(t *testing.T) {
+	a, b := net.Pipe()
+	client, server := NewMessageConn(a), NewMessageConn(b)
+	defer client.Close()
+	client.SetCompression(LZ4, 64)
+
+	// Values decompressing beyond the frame limit are refused by default
+	server.SetMaxFrameSize(200)
+	go client.Send(strings.Repeat("x", 1000))
+	_, _, err := server.Receive()
+	assert.Error(t, err, "decoding limit exceeded: MaxLength of 200, got 1000")
+
+	// The stream is intact after a value over the limits
+	server.SetReaderOptions(ReaderOptions{MaxLength: 1000, MaxEntries: 1, MaxAllocation: 1000})
+	go client.Send(strings.Repeat("x", 1000))
+	val, _, err := server.Receive()
+	assert.NilError(t, err)
+	assert.Equal(t, val, strings.Repeat("x", 1000))
+
+	go client.Send([]string{"a", "b"})
+	_, _, err = server.Receive()
+	assert.Error(t, err, "decoding limit exceeded: MaxEntries of 1, got 2")
+}
+
+func TestMessageConn_Timeouts(t *testing.T) {
+	a, b := net.Pipe()
+	client, server := NewMessageConn(a), NewMessageConn(b)
+	defer client.Close()
+
+	server.SetReadTimeout(10 * time.Millisecond)
+	_, _, err := server.Receive()
+	ne, ok := err.(net.Error)
+	assert.Assert(t, ok && ne.Timeout(), err)
+
+	client.SetWriteTimeout(10 * time.Millisecond)
+	err = client.Send("nobody is reading")
+	ne, ok = err.(net.Error)
+	assert.Assert(t, ok && ne.Timeout(), err)
+
+	// A timeout waiting for a frame does not break the stream
+	client = NewMessageConn(a)
+	assert.NilError(t, client.SetWriteDeadline(time.Time{}))
+	server.SetReadTimeout(time.Second)
+	go client.Send("late")
+	val, _, err := server.Receive()
+	assert.NilError(t, err)
+	assert.Equal(t, val, "late")
+}
+
+func TestMessageConn_UnknownFlags(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	server := NewMessageConn(b)
+
+	go a.Write([]byte{0, 0, 0, 1, 0x80, 0})
+	_, _, err := server.Receive()
+	assert.Error(t, err, "unknown frame flags 0x80")
+}
@@ -0,0 +1,62 @@
+//go:build linux
+// +build linux
+
+package cereal
+
+import (
+	"os"
+	"syscall"
+)
+
+// MmapReader is a Reader over a memory-mapped file. Bytes values, and strings when zero-copy strings are enabled, are
+// read without copying and refer to the mapped memory, so they become invalid once the reader is closed.
+type MmapReader struct {
+	*Reader
+	data []byte
+}
+
+// NewMmapReader will map the file into memory and return a reader over it.
+func NewMmapReader(filePath string) (*MmapReader, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if info.Size() > 0 {
+		data, err = syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r := NewReaderFromBuffer(data)
+	r.SetZeroCopy(true)
+	return &MmapReader{Reader: r, data: data}, nil
+}
+
+// Bytes returns the mapped file.
+func (m *MmapReader) Bytes() []byte {
+	return m.data
+}
+
+// Close will unmap the file. Reads afterwards return io.EOF.
+func (m *MmapReader) Close() error {
+	if m.data == nil {
+		return nil
+	}
+	data := m.data
+	m.data = nil
+
+	// Nothing may refer to the mapped memory once it is unmapped
+	m.Reader.r = &byteSeeker{}
+	m.Reader.index = nil
+	m.Reader.indexKeys = nil
+	return syscall.Munmap(data)
+}
@@ -0,0 +1,61 @@
+//go:build linux
+// +build linux
+
+package cereal
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestMmapReader_Read(t *testing.T) {
+	m := map[string]interface{}{
+		"blob": bytes.Repeat([]byte{0xCA, 0xFE}, 5000),
+		"name": "mapped",
+		"tags": []string{"a", "b"},
+	}
+
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	_, _, err := w.Write(m)
+	assert.NilError(t, err)
+	offset, _, err := w.Write([]byte("tail"))
+	assert.NilError(t, err)
+	_, _, err = w.Write("after close")
+	assert.NilError(t, err)
+	path := filepath.Join(t.TempDir(), "mapped")
+	assert.NilError(t, ioutil.WriteFile(path, buf.Bytes(), 0644))
+
+	r, err := NewMmapReader(path)
+	assert.NilError(t, err)
+	defer r.Close()
+
+	val, _, err := r.Read(KeyValueMap)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, val, m)
+
+	val, _, err = r.Read(Bytes)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, val, []byte("tail"))
+	assert.Equal(t, &val.([]byte)[0], &r.Bytes()[offset+2])
+	assert.NilError(t, r.Close())
+
+	// Reads after closing do not touch the unmapped memory
+	_, _, err = r.Read(Any)
+	assert.Equal(t, err, io.EOF)
+}
+
+func TestMmapReader_Empty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty")
+	assert.NilError(t, ioutil.WriteFile(path, nil, 0644))
+	r, err := NewMmapReader(path)
+	assert.NilError(t, err)
+	_, _, err = r.Read(Any)
+	assert.Assert(t, err != nil)
+	assert.NilError(t, r.Close())
+}
@@ -0,0 +1,228 @@
+package msgpack
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/bt/cereal/internal/transcode"
+)
+
+// DefaultMaxDepth is the deepest nesting of arrays and maps a decoder reads unless set otherwise.
+const DefaultMaxDepth = transcode.DefaultMaxDepth
+
+// Decoder reads MessagePack values from a stream. It may read beyond the last value it decodes.
+type Decoder struct {
+	r     *bufio.Reader
+	depth transcode.Depth
+}
+
+// NewDecoder will return a new decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r:     bufio.NewReader(r),
+		depth: transcode.Depth{Max: DefaultMaxDepth},
+	}
+}
+
+// SetMaxDepth will set the deepest nesting of arrays and maps decoded, where a top-level array or map has a depth of
+// 1, like cereal.ReaderOptions.MaxDepth. Zero is unlimited.
+func (d *Decoder) SetMaxDepth(n int) {
+	d.depth.Max = n
+}
+
+// Decode will read the next value, returning io.EOF at the end of the stream. Integers are returned as int64, or
+// uint64 when too large for int64, floats as float64, str as string, bin as []byte, arrays as []interface{}, maps as
+// map[string]interface{} and timestamps as time.Time.
+func (d *Decoder) Decode() (interface{}, error) {
+	format, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := d.decodeFormat(format)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return val, err
+}
+
+func (d *Decoder) decodeValue() (interface{}, error) {
+	format, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	return d.decodeFormat(format)
+}
+
+func (d *Decoder) decodeFormat(format byte) (interface{}, error) {
+	switch {
+	case format < 0x80:
+		return int64(format), nil
+	case format >= 0xe0:
+		return int64(int8(format)), nil
+	case format&0xf0 == fixMap:
+		return d.decodeMap(int(format & 0x0f))
+	case format&0xf0 == fixArray:
+		return d.decodeArray(int(format & 0x0f))
+	case format&0xe0 == fixStr:
+		buf, err := transcode.ReadN(d.r, uint64(format&0x1f))
+		return string(buf), err
+	}
+
+	switch format {
+	case formatNil:
+		return nil, nil
+	case formatFalse:
+		return false, nil
+	case formatTrue:
+		return true, nil
+	case formatUint8, formatUint16, formatUint32, formatUint64:
+		v, err := d.readUint(1 << (format - formatUint8))
+		if err != nil {
+			return nil, err
+		}
+		if v > math.MaxInt64 {
+			return v, nil
+		}
+		return int64(v), nil
+	case formatInt8, formatInt16, formatInt32, formatInt64:
+		size := 1 << (format - formatInt8)
+		v, err := d.readUint(size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign extend
+		shift := uint(64 - size*8)
+		return int64(v<<shift) >> shift, nil
+	case formatFloat32:
+		v, err := d.readUint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case formatFloat64:
+		v, err := d.readUint(8)
+		return math.Float64frombits(v), err
+	case formatStr8, formatStr16, formatStr32:
+		n, err := d.readUint(1 << (format - formatStr8))
+		if err != nil {
+			return nil, err
+		}
+		buf, err := transcode.ReadN(d.r, n)
+		return string(buf), err
+	case formatBin8, formatBin16, formatBin32:
+		n, err := d.readUint(1 << (format - formatBin8))
+		if err != nil {
+			return nil, err
+		}
+		return transcode.ReadN(d.r, n)
+	case formatArray16, formatArray32:
+		n, err := d.readUint(2 << (format - formatArray16))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeArray(int(n))
+	case formatMap16, formatMap32:
+		n, err := d.readUint(2 << (format - formatMap16))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeMap(int(n))
+	case formatFixExt1, formatFixExt2, formatFixExt4, formatFixExt8, formatFixExt16:
+		return d.decodeExt(1 << (format - formatFixExt1))
+	case formatExt8, formatExt16, formatExt32:
+		n, err := d.readUint(1 << (format - formatExt8))
+		if err != nil {
+			return nil, err
+		}
+		return d.decodeExt(int(n))
+	default:
+		return nil, fmt.Errorf("invalid MessagePack format 0x%02x", format)
+	}
+}
+
+func (d *Decoder) decodeArray(n int) (interface{}, error) {
+	defer d.depth.Leave()
+	if err := d.depth.Enter(); err != nil {
+		return nil, err
+	}
+
+	a := []interface{}{}
+	for i := 0; i < n; i++ {
+		v, err := d.decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+func (d *Decoder) decodeMap(n int) (interface{}, error) {
+	defer d.depth.Leave()
+	if err := d.depth.Enter(); err != nil {
+		return nil, err
+	}
+
+	m := map[string]interface{}{}
+	for i := 0; i < n; i++ {
+		k, err := d.decodeValue()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot decode map key '%v' of type %T, keys must be strings", k, k)
+		}
+		if m[key], err = d.decodeValue(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func (d *Decoder) decodeExt(n int) (interface{}, error) {
+	extType, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := transcode.ReadN(d.r, uint64(n))
+	if err != nil {
+		return nil, err
+	}
+	if extType != timestampExt {
+		return nil, fmt.Errorf("unsupported MessagePack extension type %d", int8(extType))
+	}
+
+	var sec int64
+	var nsec uint64
+	switch n {
+	case 4:
+		sec = int64(binary.BigEndian.Uint32(data))
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		sec, nsec = int64(v&(1<<34-1)), v>>34
+	case 12:
+		sec, nsec = int64(binary.BigEndian.Uint64(data[4:])), uint64(binary.BigEndian.Uint32(data))
+	default:
+		return nil, fmt.Errorf("invalid timestamp length %d", n)
+	}
+	if nsec >= 1e9 {
+		return nil, fmt.Errorf("invalid timestamp nanoseconds %d", nsec)
+	}
+	return time.Unix(sec, int64(nsec)).UTC(), nil
+}
+
+// readUint will read a big-endian unsigned integer of size bytes.
+func (d *Decoder) readUint(size int) (uint64, error) {
+	buf, err := transcode.ReadN(d.r, uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, b := range buf {
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
@@ -0,0 +1,171 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// Encode will write the value as MessagePack.
+func Encode(w io.Writer, v interface{}) error {
+	buf, err := appendValue(nil, v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buf)
+	return err
+}
+
+func appendValue(b []byte, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, formatNil), nil
+	case bool:
+		if v {
+			return append(b, formatTrue), nil
+		}
+		return append(b, formatFalse), nil
+	case int:
+		return appendInt(b, int64(v)), nil
+	case int8:
+		return appendInt(b, int64(v)), nil
+	case int16:
+		return appendInt(b, int64(v)), nil
+	case int32:
+		return appendInt(b, int64(v)), nil
+	case int64:
+		return appendInt(b, v), nil
+	case uint:
+		return appendUint(b, uint64(v)), nil
+	case uint8:
+		return appendUint(b, uint64(v)), nil
+	case uint16:
+		return appendUint(b, uint64(v)), nil
+	case uint32:
+		return appendUint(b, uint64(v)), nil
+	case uint64:
+		return appendUint(b, v), nil
+	case float32:
+		return appendUint32(append(b, formatFloat32), math.Float32bits(v)), nil
+	case float64:
+		return appendUint64(append(b, formatFloat64), math.Float64bits(v)), nil
+	case string:
+		return append(appendLength(b, len(v), fixStr, 32, formatStr8, formatStr16, formatStr32), v...), nil
+	case []byte:
+		return append(appendLength(b, len(v), 0, 0, formatBin8, formatBin16, formatBin32), v...), nil
+	case []string:
+		b = appendLength(b, len(v), fixArray, 16, 0, formatArray16, formatArray32)
+		for _, s := range v {
+			b = append(appendLength(b, len(s), fixStr, 32, formatStr8, formatStr16, formatStr32), s...)
+		}
+		return b, nil
+	case []interface{}:
+		b = appendLength(b, len(v), fixArray, 16, 0, formatArray16, formatArray32)
+		for _, e := range v {
+			var err error
+			if b, err = appendValue(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		b = appendLength(b, len(v), fixMap, 16, 0, formatMap16, formatMap32)
+		for _, k := range keys {
+			b = append(appendLength(b, len(k), fixStr, 32, formatStr8, formatStr16, formatStr32), k...)
+			var err error
+			if b, err = appendValue(b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case time.Time:
+		return appendTimestamp(b, v), nil
+	default:
+		return nil, fmt.Errorf("cannot encode value '%v' of type %T as MessagePack", v, v)
+	}
+}
+
+func appendInt(b []byte, v int64) []byte {
+	switch {
+	case v >= 0:
+		return appendUint(b, uint64(v))
+	case v >= -32:
+		return append(b, byte(v))
+	case v >= math.MinInt8:
+		return append(b, formatInt8, byte(v))
+	case v >= math.MinInt16:
+		return appendUint16(append(b, formatInt16), uint16(v))
+	case v >= math.MinInt32:
+		return appendUint32(append(b, formatInt32), uint32(v))
+	default:
+		return appendUint64(append(b, formatInt64), uint64(v))
+	}
+}
+
+func appendUint(b []byte, v uint64) []byte {
+	switch {
+	case v < 128:
+		return append(b, byte(v))
+	case v <= math.MaxUint8:
+		return append(b, formatUint8, byte(v))
+	case v <= math.MaxUint16:
+		return appendUint16(append(b, formatUint16), uint16(v))
+	case v <= math.MaxUint32:
+		return appendUint32(append(b, formatUint32), uint32(v))
+	default:
+		return appendUint64(append(b, formatUint64), v)
+	}
+}
+
+// appendLength will append the header of a value of length n, using the fix format if n is below fixLimit.
+func appendLength(b []byte, n int, fix byte, fixLimit int, format8, format16, format32 byte) []byte {
+	switch {
+	case n < fixLimit:
+		return append(b, fix|byte(n))
+	case format8 != 0 && n <= math.MaxUint8:
+		return append(b, format8, byte(n))
+	case n <= math.MaxUint16:
+		return appendUint16(append(b, format16), uint16(n))
+	default:
+		return appendUint32(append(b, format32), uint32(n))
+	}
+}
+
+// appendTimestamp will append the time using the smallest timestamp format holding it.
+func appendTimestamp(b []byte, t time.Time) []byte {
+	sec, nsec := t.Unix(), uint32(t.Nanosecond())
+	switch {
+	case sec >= 0 && sec>>34 == 0 && nsec == 0 && sec <= math.MaxUint32:
+		return appendUint32(append(b, formatFixExt4, timestampExt), uint32(sec))
+	case sec >= 0 && sec>>34 == 0:
+		return appendUint64(append(b, formatFixExt8, timestampExt), uint64(nsec)<<34|uint64(sec))
+	default:
+		b = appendUint32(append(b, formatExt8, 12, timestampExt), nsec)
+		return appendUint64(b, uint64(sec))
+	}
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,111 @@
+// Package msgpack transcodes between cereal values and MessagePack.
+//
+// Values map to MessagePack as follows:
+//
+//	bool                    bool
+//	int64                   int, using the smallest format holding the value
+//	uint64                  uint, using the smallest format holding the value
+//	float64                 float 64
+//	string                  str
+//	[]byte                  bin
+//	[]string                array of str
+//	map[string]interface{}  map with str keys
+//	time.Time               timestamp extension
+//	nil                     nil
+//
+// Cereal has no nil, array of mixed types or timestamp data types. Timestamps are transcoded to cereal as Integer Unix
+// nanoseconds; nil values and arrays holding other than strings cannot be transcoded to cereal.
+package msgpack
+
+import (
+	"io"
+
+	"github.com/bt/cereal"
+	"github.com/bt/cereal/internal/transcode"
+)
+
+// Formats of the MessagePack specification.
+const (
+	formatNil      byte = 0xc0
+	formatFalse    byte = 0xc2
+	formatTrue     byte = 0xc3
+	formatBin8     byte = 0xc4
+	formatBin16    byte = 0xc5
+	formatBin32    byte = 0xc6
+	formatExt8     byte = 0xc7
+	formatExt16    byte = 0xc8
+	formatExt32    byte = 0xc9
+	formatFloat32  byte = 0xca
+	formatFloat64  byte = 0xcb
+	formatUint8    byte = 0xcc
+	formatUint16   byte = 0xcd
+	formatUint32   byte = 0xce
+	formatUint64   byte = 0xcf
+	formatInt8     byte = 0xd0
+	formatInt16    byte = 0xd1
+	formatInt32    byte = 0xd2
+	formatInt64    byte = 0xd3
+	formatFixExt1  byte = 0xd4
+	formatFixExt2  byte = 0xd5
+	formatFixExt4  byte = 0xd6
+	formatFixExt8  byte = 0xd7
+	formatFixExt16 byte = 0xd8
+	formatStr8     byte = 0xd9
+	formatStr16    byte = 0xda
+	formatStr32    byte = 0xdb
+	formatArray16  byte = 0xdc
+	formatArray32  byte = 0xdd
+	formatMap16    byte = 0xde
+	formatMap32    byte = 0xdf
+
+	fixMap   byte = 0x80
+	fixArray byte = 0x90
+	fixStr   byte = 0xa0
+)
+
+// timestampExt is the extension type of timestamps, -1.
+const timestampExt byte = 0xff
+
+// ValueEncoder makes a cereal.Writer write MessagePack, when set with Writer.SetValueEncoder.
+var ValueEncoder cereal.ValueEncoder = valueEncoder{}
+
+type valueEncoder struct{}
+
+func (valueEncoder) EncodeValue(w io.Writer, v interface{}) error {
+	return Encode(w, v)
+}
+
+// ToMessagePack will read cereal values until the end of the reader and write each as MessagePack.
+func ToMessagePack(r *cereal.Reader, w io.Writer) error {
+	for {
+		val, _, err := r.Read(cereal.Any)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err = Encode(w, val); err != nil {
+			return err
+		}
+	}
+}
+
+// FromMessagePack will read MessagePack values until the end of the reader and write each as a cereal value.
+func FromMessagePack(r io.Reader, w *cereal.Writer) error {
+	d := NewDecoder(r)
+	for {
+		val, err := d.Decode()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if val, err = transcode.ToCereal(val, "nil"); err != nil {
+			return err
+		}
+		if _, _, err = w.Write(val); err != nil {
+			return err
+		}
+	}
+}
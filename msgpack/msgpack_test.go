@@ -0,0 +1,155 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/bt/cereal"
+	"gotest.tools/assert"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+		decoded  interface{}
+	}{
+		{nil, "c0", nil},
+		{true, "c3", true},
+		{int64(5), "05", int64(5)},
+		{int64(-32), "e0", int64(-32)},
+		{int64(-33), "d0df", int64(-33)},
+		{int64(-129), "d1ff7f", int64(-129)},
+		{int64(math.MinInt64), "d38000000000000000", int64(math.MinInt64)},
+		{uint64(200), "ccc8", int64(200)},
+		{uint64(70000), "ce00011170", int64(70000)},
+		{uint64(math.MaxUint64), "cfffffffffffffffff", uint64(math.MaxUint64)},
+		{1.5, "cb3ff8000000000000", 1.5},
+		{float32(0.5), "ca3f000000", 0.5},
+		{"hi", "a26869", "hi"},
+		{string(make([]byte, 40)), "d928" + hex.EncodeToString(make([]byte, 40)), string(make([]byte, 40))},
+		{[]byte{1, 2}, "c4020102", []byte{1, 2}},
+		{[]string{"a"}, "91a161", []interface{}{"a"}},
+		{[]interface{}{nil, int64(1)}, "92c001", []interface{}{nil, int64(1)}},
+		{map[string]interface{}{"b": false, "a": int64(1)}, "82a16101a162c2", map[string]interface{}{"a": int64(1), "b": false}},
+		{time.Unix(1, 0).UTC(), "d6ff00000001", time.Unix(1, 0).UTC()},
+		{time.Unix(1, 5).UTC(), "d7ff0000001400000001", time.Unix(1, 5).UTC()},
+		{time.Unix(-1, 5).UTC(), "c70cff00000005ffffffffffffffff", time.Unix(-1, 5).UTC()},
+	}
+	for _, tt := range tests {
+		buf := new(bytes.Buffer)
+		assert.NilError(t, Encode(buf, tt.value))
+		assert.Equal(t, hex.EncodeToString(buf.Bytes()), tt.expected)
+
+		val, err := NewDecoder(buf).Decode()
+		assert.NilError(t, err)
+		assert.DeepEqual(t, val, tt.decoded)
+	}
+
+	assert.Error(t, Encode(new(bytes.Buffer), struct{}{}), "cannot encode value '{}' of type struct {} as MessagePack")
+}
+
+func TestWriter_ValueEncoder(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := cereal.NewWriterFromBuffer(buf)
+	w.SetValueEncoder(ValueEncoder)
+
+	offset, length, err := w.Write(map[string]interface{}{"a": int64(1)})
+	assert.NilError(t, err)
+	assert.Equal(t, offset, uint64(0))
+	assert.Equal(t, length, 4)
+	offset, length, err = w.Write(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, offset, uint64(4))
+	assert.Equal(t, length, 1)
+	assert.Equal(t, hex.EncodeToString(buf.Bytes()), "81a16101c0")
+}
+
+func TestTranscode(t *testing.T) {
+	values := []interface{}{
+		map[string]interface{}{"name": "x", "n": int64(-4), "tags": []string{"a", "b"}, "raw": []byte{0}},
+		uint64(math.MaxUint64),
+		2.5,
+		"end",
+	}
+
+	buf := new(bytes.Buffer)
+	w := cereal.NewWriterFromBuffer(buf)
+	for _, v := range values {
+		_, _, err := w.Write(v)
+		assert.NilError(t, err)
+	}
+
+	packed := new(bytes.Buffer)
+	assert.NilError(t, ToMessagePack(cereal.NewReaderFromBuffer(buf.Bytes()), packed))
+
+	out := new(bytes.Buffer)
+	assert.NilError(t, FromMessagePack(packed, cereal.NewWriterFromBuffer(out)))
+	r := cereal.NewReaderFromBuffer(out.Bytes())
+	for _, expected := range values {
+		val, _, err := r.Read(cereal.Any)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, val, expected)
+	}
+	_, _, err := r.Read(cereal.Any)
+	assert.Equal(t, err, io.EOF)
+
+	// Timestamps are written as Unix nanoseconds
+	packed.Reset()
+	out.Reset()
+	assert.NilError(t, Encode(packed, time.Unix(2, 3)))
+	assert.NilError(t, FromMessagePack(packed, cereal.NewWriterFromBuffer(out)))
+	val, _, err := cereal.NewReaderFromBuffer(out.Bytes()).Read(cereal.Integer)
+	assert.NilError(t, err)
+	assert.Equal(t, val, int64(2000000003))
+}
+
+func TestFromMessagePack_Errors(t *testing.T) {
+	tests := map[string]string{
+		"c0":                             "cannot transcode nil to cereal",
+		"9201a161":                       "cannot transcode array with non-string element '1' to cereal",
+		"8101c0":                         "cannot decode map key '1' of type int64, keys must be strings",
+		"c1":                             "invalid MessagePack format 0xc1",
+		"d40100":                         "unsupported MessagePack extension type 1",
+		"d5ff0000":                       "invalid timestamp length 2",
+		"d7ffee6b280000000000":           "invalid timestamp nanoseconds 1000000000",
+		"c70cff3b9aca000000000000000000": "invalid timestamp nanoseconds 1000000000",
+		"a3616263c1":                     "invalid MessagePack format 0xc1",
+		"a36162":                         "unexpected EOF",
+		"92":                             "unexpected EOF",
+	}
+	for input, expected := range tests {
+		b, err := hex.DecodeString(input)
+		assert.NilError(t, err)
+		err = FromMessagePack(bytes.NewReader(b), cereal.NewWriterFromBuffer(new(bytes.Buffer)))
+		assert.Error(t, err, expected, input)
+	}
+}
+
+func TestDecoder_MaxDepth(t *testing.T) {
+	b, err := hex.DecodeString("9181a1619101")
+	assert.NilError(t, err)
+
+	d := NewDecoder(bytes.NewReader(b))
+	d.SetMaxDepth(2)
+	_, err = d.Decode()
+	assert.Error(t, err, "decoding limit exceeded: MaxDepth of 2, got 3")
+
+	d = NewDecoder(bytes.NewReader(b))
+	d.SetMaxDepth(3)
+	val, err := d.Decode()
+	assert.NilError(t, err)
+	assert.DeepEqual(t, val, []interface{}{map[string]interface{}{"a": []interface{}{int64(1)}}})
+
+	// Deeply nested input is refused by default
+	deep := bytes.Repeat([]byte{0x91}, DefaultMaxDepth+1)
+	_, err = NewDecoder(bytes.NewReader(append(deep, 0x01))).Decode()
+	var limitErr *cereal.LimitExceededError
+	assert.Assert(t, errors.As(err, &limitErr))
+	assert.Equal(t, limitErr.Limit, "MaxDepth")
+}
@@ -34,11 +34,11 @@ func uint64Value(n interface{}) uint64 {
 	panic("could not convert to uint64")
 }
 
-// floatValue will convert the provided value to a float otherwise panic.
+// floatValue will convert the provided value to a float64, the width of Float values, otherwise panic.
 func floatValue(n interface{}) interface{} {
 	switch n := n.(type) {
 	case float32:
-		return float32(n)
+		return float64(n)
 	case float64:
 		return float64(n)
 	}
@@ -0,0 +1,244 @@
+// Package orderedkey encodes tuples of values into keys whose lexicographic byte order, as given by bytes.Compare,
+// matches the order of the values.
+//
+// Each component is a type tag followed by the encoded value:
+//
+//	bool     0 or 1
+//	int64    8 big-endian bytes with the sign bit flipped
+//	uint64   8 big-endian bytes
+//	float64  8 big-endian bytes of the IEEE 754 bits, with the sign bit flipped for positive values and all bits flipped
+//	         for negative values
+//	string   bytes with 0x00 escaped as 0x00 0xff, terminated by 0x00 0x01
+//	[]byte   as string
+//	time     int64 Unix seconds followed by 4 big-endian bytes of nanoseconds
+//
+// Components of different types order by their tag. Descending components have every byte, including the tag,
+// inverted.
+package orderedkey
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Type tags of ascending components. Descending components use the inverted tag.
+const (
+	tagBool   byte = 0x01
+	tagInt    byte = 0x02
+	tagUint   byte = 0x03
+	tagFloat  byte = 0x04
+	tagString byte = 0x05
+	tagBytes  byte = 0x06
+	tagTime   byte = 0x07
+)
+
+const (
+	escape     byte = 0x00
+	escaped00  byte = 0xff
+	terminator byte = 0x01
+)
+
+// descending marks a component which is encoded in descending order.
+type descending struct {
+	v interface{}
+}
+
+// Desc will mark the component to be encoded in descending order.
+func Desc(v interface{}) interface{} {
+	return descending{v}
+}
+
+// Encode will encode the components into a key.
+func Encode(components ...interface{}) ([]byte, error) {
+	return Append(nil, components...)
+}
+
+// Append will append the key encoding the components to dst.
+func Append(dst []byte, components ...interface{}) ([]byte, error) {
+	for _, c := range components {
+		desc := false
+		if d, ok := c.(descending); ok {
+			c, desc = d.v, true
+		}
+
+		start := len(dst)
+		var err error
+		if dst, err = appendComponent(dst, c); err != nil {
+			return nil, err
+		}
+		if desc {
+			for i := start; i < len(dst); i++ {
+				dst[i] = ^dst[i]
+			}
+		}
+	}
+	return dst, nil
+}
+
+func appendComponent(dst []byte, c interface{}) ([]byte, error) {
+	switch v := c.(type) {
+	case bool:
+		b := byte(0)
+		if v {
+			b = 1
+		}
+		return append(dst, tagBool, b), nil
+	case int64:
+		return appendUint64(append(dst, tagInt), uint64(v)^(1<<63)), nil
+	case uint64:
+		return appendUint64(append(dst, tagUint), v), nil
+	case float64:
+		bits := math.Float64bits(v)
+		if bits&(1<<63) != 0 {
+			bits = ^bits
+		} else {
+			bits |= 1 << 63
+		}
+		return appendUint64(append(dst, tagFloat), bits), nil
+	case string:
+		return appendEscaped(append(dst, tagString), []byte(v)), nil
+	case []byte:
+		return appendEscaped(append(dst, tagBytes), v), nil
+	case time.Time:
+		var nsec [4]byte
+		binary.BigEndian.PutUint32(nsec[:], uint32(v.Nanosecond()))
+		return append(appendUint64(append(dst, tagTime), uint64(v.Unix())^(1<<63)), nsec[:]...), nil
+	case descending:
+		return nil, fmt.Errorf("component is already descending")
+	default:
+		return nil, fmt.Errorf("unsupported key component type %T", c)
+	}
+}
+
+func appendUint64(dst []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(dst, buf[:]...)
+}
+
+func appendEscaped(dst []byte, b []byte) []byte {
+	for _, c := range b {
+		if c == escape {
+			dst = append(dst, escape, escaped00)
+		} else {
+			dst = append(dst, c)
+		}
+	}
+	return append(dst, escape, terminator)
+}
+
+// Decode will decode the components of the key. Descending components are returned as plain values.
+func Decode(key []byte) ([]interface{}, error) {
+	var components []interface{}
+	for len(key) > 0 {
+		c, n, err := decodeComponent(key)
+		if err != nil {
+			return nil, err
+		}
+		components = append(components, c)
+		key = key[n:]
+	}
+	return components, nil
+}
+
+// decodeComponent will decode the first component of the key and return its length.
+func decodeComponent(key []byte) (interface{}, int, error) {
+	tag := key[0]
+	desc := tag&0x80 != 0
+	if desc {
+		tag = ^tag
+	}
+
+	// Read returns the next n bytes of the component, inverting descending components
+	offset := 1
+	read := func(n int) ([]byte, error) {
+		if len(key)-offset < n {
+			return nil, fmt.Errorf("truncated key component with tag 0x%02x", key[0])
+		}
+		b := key[offset : offset+n]
+		offset += n
+		if !desc {
+			return b, nil
+		}
+		inv := make([]byte, n)
+		for i := range b {
+			inv[i] = ^b[i]
+		}
+		return inv, nil
+	}
+
+	var c interface{}
+	switch tag {
+	case tagBool:
+		b, err := read(1)
+		if err != nil {
+			return nil, 0, err
+		}
+		if b[0] > 1 {
+			return nil, 0, fmt.Errorf("invalid bool key component 0x%02x", b[0])
+		}
+		c = b[0] == 1
+	case tagInt, tagUint, tagFloat:
+		b, err := read(8)
+		if err != nil {
+			return nil, 0, err
+		}
+		v := binary.BigEndian.Uint64(b)
+		switch tag {
+		case tagInt:
+			c = int64(v ^ (1 << 63))
+		case tagUint:
+			c = v
+		case tagFloat:
+			if v&(1<<63) != 0 {
+				v &^= 1 << 63
+			} else {
+				v = ^v
+			}
+			c = math.Float64frombits(v)
+		}
+	case tagString, tagBytes:
+		var buf []byte
+		for {
+			b, err := read(1)
+			if err != nil {
+				return nil, 0, err
+			}
+			if b[0] != escape {
+				buf = append(buf, b[0])
+				continue
+			}
+			if b, err = read(1); err != nil {
+				return nil, 0, err
+			}
+			if b[0] == terminator {
+				break
+			}
+			if b[0] != escaped00 {
+				return nil, 0, fmt.Errorf("invalid escape 0x00 0x%02x in key component", b[0])
+			}
+			buf = append(buf, escape)
+		}
+		if tag == tagString {
+			c = string(buf)
+		} else {
+			if buf == nil {
+				buf = []byte{}
+			}
+			c = buf
+		}
+	case tagTime:
+		b, err := read(12)
+		if err != nil {
+			return nil, 0, err
+		}
+		sec := int64(binary.BigEndian.Uint64(b) ^ (1 << 63))
+		nsec := int64(binary.BigEndian.Uint32(b[8:]))
+		c = time.Unix(sec, nsec).UTC()
+	default:
+		return nil, 0, fmt.Errorf("unknown key component tag 0x%02x", key[0])
+	}
+	return c, offset, nil
+}
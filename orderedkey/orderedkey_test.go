@@ -0,0 +1,118 @@
+package orderedkey
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"gotest.tools/assert"
+)
+
+func TestEncode_Order(t *testing.T) {
+	base := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	tests := map[string][]interface{}{
+		"int64":   {int64(math.MinInt64), int64(-1000), int64(-1), int64(0), int64(1), int64(256), int64(math.MaxInt64)},
+		"uint64":  {uint64(0), uint64(1), uint64(255), uint64(256), uint64(math.MaxUint64)},
+		"float64": {math.Inf(-1), -1e300, -1.5, -math.SmallestNonzeroFloat64, 0.0, math.SmallestNonzeroFloat64, 0.5, 1e300, math.Inf(1)},
+		"string":  {"", "\x00", "\x00\x00", "\x00a", "a", "a\x00", "a\x00b", "ab", "b"},
+		"bytes":   {[]byte{}, []byte{0}, []byte{0, 0xff}, []byte{1}, []byte{0xff}, []byte{0xff, 0}},
+		"bool":    {false, true},
+		"time":    {time.Unix(-1, 0).UTC(), time.Unix(0, 0).UTC(), base, base.Add(1), base.Add(time.Second)},
+	}
+	for name, values := range tests {
+		t.Run(name, func(t *testing.T) {
+			for _, desc := range []bool{false, true} {
+				var keys [][]byte
+				for _, v := range values {
+					c := v
+					if desc {
+						c = Desc(v)
+					}
+					key, err := Encode(c)
+					assert.NilError(t, err)
+					keys = append(keys, key)
+
+					decoded, err := Decode(key)
+					assert.NilError(t, err)
+					assert.DeepEqual(t, decoded, []interface{}{v})
+				}
+				for i := 1; i < len(keys); i++ {
+					cmp := bytes.Compare(keys[i-1], keys[i])
+					if desc {
+						assert.Equal(t, cmp, 1, "%v before %v", values[i-1], values[i])
+					} else {
+						assert.Equal(t, cmp, -1, "%v before %v", values[i-1], values[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEncode_Tuples(t *testing.T) {
+	type row struct {
+		user  string
+		at    int64
+		score float64
+	}
+	rows := []row{
+		{"bob", 3, 1.5},
+		{"alice", 2, 9},
+		{"alice", 2, 10},
+		{"alice", 1, -3},
+		{"al", 5, 0},
+		{"bob", 3, -1},
+	}
+
+	// Sort by user ascending, time descending, score ascending
+	var keys [][]byte
+	for _, r := range rows {
+		key, err := Encode(r.user, Desc(r.at), r.score)
+		assert.NilError(t, err)
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+
+	expected := []row{
+		{"al", 5, 0},
+		{"alice", 2, 9},
+		{"alice", 2, 10},
+		{"alice", 1, -3},
+		{"bob", 3, -1},
+		{"bob", 3, 1.5},
+	}
+	for i, key := range keys {
+		decoded, err := Decode(key)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, decoded, []interface{}{expected[i].user, expected[i].at, expected[i].score})
+	}
+}
+
+func TestEncode_Errors(t *testing.T) {
+	_, err := Encode(int32(1))
+	assert.Error(t, err, "unsupported key component type int32")
+	_, err = Encode(Desc(Desc("a")))
+	assert.Error(t, err, "component is already descending")
+
+	key, err := Encode(int64(1), "abc")
+	assert.NilError(t, err)
+	_, err = Decode(key[:len(key)-1])
+	assert.Error(t, err, "truncated key component with tag 0x05")
+	_, err = Decode([]byte{0x42})
+	assert.Error(t, err, "unknown key component tag 0x42")
+	_, err = Decode([]byte{tagString, 'a', 0x00, 0x02})
+	assert.Error(t, err, "invalid escape 0x00 0x02 in key component")
+
+	// Append extends an existing key
+	prefix, err := Encode("user")
+	assert.NilError(t, err)
+	key, err = Append(prefix, uint64(7))
+	assert.NilError(t, err)
+	decoded, err := Decode(key)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, decoded, []interface{}{"user", uint64(7)})
+}
@@ -0,0 +1,44 @@
+package cereal
+
+import (
+	"io"
+	"math"
+)
+
+// RandomReader reads values at arbitrary offsets and is safe for concurrent use.
+type RandomReader struct {
+	r    io.ReaderAt
+	opts ReaderOptions
+}
+
+// NewRandomReader will return a new random reader.
+func NewRandomReader(r io.ReaderAt) *RandomReader {
+	return &RandomReader{r: r}
+}
+
+// SetOptions will set the limits of values read afterwards. It must not be called concurrently with ReadValueAt.
+func (r *RandomReader) SetOptions(opts ReaderOptions) {
+	r.opts = opts
+}
+
+// ReadValueAt will read the value at the offset, as returned by Writer.Write, and return its type and length.
+func (r *RandomReader) ReadValueAt(offset uint64) (interface{}, DataType, int, error) {
+	if offset > math.MaxInt64 {
+		return nil, 0, 0, io.EOF
+	}
+
+	// Each read uses its own cursor so reads do not interfere with each other
+	section := io.NewSectionReader(r.r, int64(offset), math.MaxInt64-int64(offset))
+	reader := NewReader(section)
+	reader.SetOptions(r.opts)
+	val, dataType, err := reader.Read(Any)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+
+	length, err := section.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return val, dataType, int(length), nil
+}
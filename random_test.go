@@ -0,0 +1,83 @@
+package cereal
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestRandomReader_ReadValueAt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cereal")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	f, err := os.Create(filepath.Join(dir, "values"))
+	assert.NilError(t, err)
+	w := NewWriter(f)
+
+	type written struct {
+		val    interface{}
+		offset uint64
+		length int
+	}
+	var values []written
+	for i := 0; i < 1000; i++ {
+		var val interface{} = int64(i)
+		switch i % 3 {
+		case 1:
+			val = fmt.Sprintf("value %d", i)
+		case 2:
+			val = map[string]interface{}{"i": uint64(i), "tags": []string{"x", "y"}}
+		}
+		offset, length, err := w.Write(val)
+		assert.NilError(t, err)
+		values = append(values, written{val, offset, length})
+	}
+	assert.NilError(t, w.Close())
+
+	f, err = os.Open(filepath.Join(dir, "values"))
+	assert.NilError(t, err)
+	defer f.Close()
+	content, err := ioutil.ReadFile(filepath.Join(dir, "values"))
+	assert.NilError(t, err)
+
+	for name, r := range map[string]*RandomReader{
+		"file":   NewRandomReader(f),
+		"buffer": NewRandomReader(bytes.NewReader(content)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			errs := make([]error, 8)
+			for g := range errs {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := len(values) - 1 - g; i >= 0; i -= len(errs) {
+						val, _, length, err := r.ReadValueAt(values[i].offset)
+						if err != nil {
+							errs[g] = fmt.Errorf("value %d at %d: %v", i, values[i].offset, err)
+							return
+						}
+						if length != values[i].length || fmt.Sprint(val) != fmt.Sprint(values[i].val) {
+							errs[g] = fmt.Errorf("value %d: got %v (%d bytes), want %v (%d bytes)", i, val, length, values[i].val, values[i].length)
+							return
+						}
+					}
+				}(g)
+			}
+			wg.Wait()
+			for _, err := range errs {
+				assert.NilError(t, err)
+			}
+		})
+	}
+
+	_, _, _, err = NewRandomReader(f).ReadValueAt(uint64(len(content)))
+	assert.Assert(t, err != nil)
+}
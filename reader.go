@@ -7,8 +7,7 @@ import (
 	"io"
 	"math"
 	"os"
-
-	"github.com/pierrec/lz4"
+	"unsafe"
 )
 
 type byteSeeker struct {
@@ -32,39 +31,106 @@ func (b *byteSeeker) Read(p []byte) (n int, err error) {
 	return n, nil
 }
 
+func (b *byteSeeker) ReadByte() (byte, error) {
+	if b.offset >= int64(len(b.buf)) {
+		return 0, io.EOF
+	}
+	c := b.buf[b.offset]
+	b.offset++
+	return c, nil
+}
+
+// oneByteReader reads single bytes from readers which are not an io.ByteReader.
+type oneByteReader struct {
+	r io.Reader
+}
+
+func (o oneByteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(o.r, b[:])
+	return b[0], err
+}
+
 func (b *byteSeeker) Seek(offset int64, whence int) (int64, error) {
 	switch whence {
 	case io.SeekStart:
-		b.offset = offset
 	case io.SeekCurrent:
-		b.offset += offset
+		offset += b.offset
 	case io.SeekEnd:
-		b.offset = int64(len(b.buf)) - 1 + offset
+		offset += int64(len(b.buf))
 	default:
 		return 0, fmt.Errorf("invalid whence")
 	}
 
-	if b.offset > int64(len(b.buf)-1) {
-		return 0, io.EOF
-	} else if b.offset < 0 {
+	// Seeking past the end is allowed, reads from there return io.EOF
+	if offset < 0 {
 		return 0, fmt.Errorf("invalid offset")
 	}
 
+	b.offset = offset
 	return b.offset, nil
 }
 
 type Reader struct {
 	r io.ReadSeeker
+
+	// Codec of the compressed section currently being read by ReadCompressedBlock
+	sectionCodec Codec
+
+	decompressionWorkers int
+
+	// Error returned by all reads after verification has failed
+	err error
+
+	// Index of top-level values, loaded by Index
+	index     []IndexEntry
+	indexKeys map[string]int
+
+	// Bloom filter over the keys of the index, loaded by MayContain
+	filter       *bloomFilter
+	filterLoaded bool
+
+	// Whether values read from a buffer refer to the buffer instead of being copied
+	zeroCopy        bool
+	zeroCopyStrings bool
+
+	// Limits of values read, and the depth and allocation of the value being read
+	opts      ReaderOptions
+	depth     int
+	allocated uint64
 }
 
+// NewReader will return a reader of the values in r, without ReaderOptions limits. Lengths are allocated as the bytes
+// arrive, so a corrupt length cannot allocate more than the input holds, but compressed sections and nested maps are
+// unbounded; set ReaderOptions with SetOptions when reading untrusted input.
 func NewReader(r io.ReadSeeker) *Reader {
 	return &Reader{r: r}
 }
 
+// NewReaderFromBuffer will return a reader of the values in buf, without ReaderOptions limits, like NewReader.
 func NewReaderFromBuffer(buf []byte) *Reader {
 	return &Reader{r: &byteSeeker{buf: buf}}
 }
 
+// SetDecompressionWorkers will set the number of blocks that compressed sections opened afterwards read ahead and
+// decompress in parallel.
+func (r *Reader) SetDecompressionWorkers(n int) {
+	r.decompressionWorkers = n
+}
+
+// SetZeroCopy will toggle whether Bytes values read from a buffer are sub-slices of the buffer instead of copies.
+// The values must not be modified and share the lifetime of the buffer.
+func (r *Reader) SetZeroCopy(b bool) {
+	r.zeroCopy = b
+}
+
+// SetZeroCopyStrings will toggle whether strings read from a buffer refer to the buffer's memory instead of being
+// copied. Modifying the buffer, or unmapping it, changes or invalidates every string read from it, so this must only
+// be used when the buffer outlives the strings and is never modified.
+func (r *Reader) SetZeroCopyStrings(b bool) {
+	r.zeroCopyStrings = b
+}
+
 func (r *Reader) readByte() (byte, error) {
 	b := make([]byte, 1)
 	_, err := r.r.Read(b)
@@ -72,73 +138,96 @@ func (r *Reader) readByte() (byte, error) {
 }
 
 func (r *Reader) readBytes(buf []byte) (err error) {
-	_, err = r.r.Read(buf)
+	_, err = io.ReadFull(r.r, buf)
 	return err
 }
 
-func (r *Reader) readString() (string, DataType, error) {
-	len, _, err := r.readUint()
-	if err != nil {
-		return "", String, err
+// readSlice will read the next n bytes, which are a sub-slice of the buffer when reading a buffer without copying.
+func (r *Reader) readSlice(n uint64, zeroCopy bool) ([]byte, error) {
+	b, ok := r.r.(*byteSeeker)
+	if !ok {
+		return r.readChunks(n)
 	}
 
-	str := make([]byte, len)
-	if err = r.readBytes(str); err != nil {
-		return "", String, err
+	// Check the length against the buffer before allocating
+	if n > uint64(int64(len(b.buf))-b.offset) {
+		b.offset = int64(len(b.buf))
+		return nil, io.ErrUnexpectedEOF
 	}
-	return string(str), String, nil
+	if !zeroCopy {
+		buf := make([]byte, n)
+		err := r.readBytes(buf)
+		return buf, err
+	}
+
+	// Limit the capacity so appending to the value cannot overwrite the buffer
+	end := b.offset + int64(n)
+	buf := b.buf[b.offset:end:end]
+	b.offset = end
+	return buf, nil
 }
 
-func (r *Reader) readInt() (int64, DataType, error) {
-	b := make([]byte, binary.MaxVarintLen64)
-	n, err := r.r.Read(b)
-	if err != io.EOF && err != nil {
-		return 0, Integer, err
+// readChunks will read the next n bytes of a stream, growing the buffer as they arrive so corrupt lengths do not
+// allocate up front.
+func (r *Reader) readChunks(n uint64) ([]byte, error) {
+	if n <= readChunkSize {
+		buf := make([]byte, n)
+		err := r.readBytes(buf)
+		return buf, err
 	}
-
-	val, nn := binary.Varint(b)
-	if nn > 0 {
-		rewindBytes := n - nn
-		if rewindBytes > 0 {
-			_, err = r.r.Seek(-int64(rewindBytes), io.SeekCurrent)
-		}
-		return val, Integer, err
+	if n > math.MaxInt64 {
+		return nil, io.ErrUnexpectedEOF
 	}
 
-	if nn == 0 {
-		return 0, Integer, fmt.Errorf("buf too small")
-	} else {
-		return 0, Integer, fmt.Errorf("overflow")
+	buf := new(bytes.Buffer)
+	buf.Grow(readChunkSize)
+	read, err := io.CopyN(buf, r.r, int64(n))
+	if err == io.EOF && read > 0 {
+		err = io.ErrUnexpectedEOF
 	}
+	return buf.Bytes(), err
 }
 
-func (r *Reader) readUint() (uint64, DataType, error) {
-	b := make([]byte, binary.MaxVarintLen64)
-	n, err := r.r.Read(b)
-	if err != io.EOF && err != nil {
-		return 0, UnsignedInteger, err
+func (r *Reader) readString() (string, DataType, error) {
+	len, _, err := r.readUint()
+	if err != nil {
+		return "", String, err
+	}
+	if err = r.checkLength(len); err != nil {
+		return "", String, err
 	}
 
-	val, nn := binary.Uvarint(b)
-	if nn > 0 {
-		rewindBytes := n - nn
-		if rewindBytes > 0 {
-			_, err = r.r.Seek(-int64(rewindBytes), io.SeekCurrent)
-		}
-		return val, UnsignedInteger, err
+	str, err := r.readSlice(len, r.zeroCopy || r.zeroCopyStrings)
+	if err != nil {
+		return "", String, err
+	}
+	if r.zeroCopyStrings {
+		return *(*string)(unsafe.Pointer(&str)), String, nil
 	}
+	return string(str), String, nil
+}
 
-	if nn == 0 {
-		return 0, UnsignedInteger, fmt.Errorf("buf too small")
-	} else {
-		return 0, UnsignedInteger, fmt.Errorf("overflow")
+// byteReader returns the input as an io.ByteReader, so varints are read a byte at a time and never past their end.
+func (r *Reader) byteReader() io.ByteReader {
+	if br, ok := r.r.(io.ByteReader); ok {
+		return br
 	}
+	return oneByteReader{r.r}
+}
+
+func (r *Reader) readInt() (int64, DataType, error) {
+	val, err := binary.ReadVarint(r.byteReader())
+	return val, Integer, err
+}
+
+func (r *Reader) readUint() (uint64, DataType, error) {
+	val, err := binary.ReadUvarint(r.byteReader())
+	return val, UnsignedInteger, err
 }
 
 func (r *Reader) readFloat() (float64, DataType, error) {
 	b := make([]byte, 8)
-	_, err := r.r.Read(b)
-	if err != io.EOF && err != nil {
+	if _, err := io.ReadFull(r.r, b); err != nil {
 		return 0, Float, err
 	}
 
@@ -148,12 +237,19 @@ func (r *Reader) readFloat() (float64, DataType, error) {
 
 func (r *Reader) readKeyValueMap() (map[string]interface{}, DataType, error) {
 	m := make(map[string]interface{})
+	defer r.leave()
+	if err := r.enter(); err != nil {
+		return nil, KeyValueMap, err
+	}
 
 	// Read length
 	len, _, err := r.readUint()
 	if err != nil {
 		return nil, KeyValueMap, err
 	}
+	if err = r.checkEntries(len, keyValueMapEntrySize); err != nil {
+		return nil, KeyValueMap, err
+	}
 
 	for i := uint64(0); i < len; i++ {
 		// Read key
@@ -176,25 +272,83 @@ func (r *Reader) readKeyValueMap() (map[string]interface{}, DataType, error) {
 
 // Read will read the next value out of the buffer.
 func (r *Reader) Read(expectedType DataType) (interface{}, DataType, error) {
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+	if err := r.leaveSections(); err != nil {
+		return nil, 0, err
+	}
+
 	t, err := r.readByte()
 	if err != nil {
 		return nil, 0, err
 	}
 
-	if expectedType != Any && DataType(t) != expectedType {
+	if expectedType != Any && uncompressedType(DataType(t)) != expectedType {
 		return nil, 0, fmt.Errorf("expected data type mismatch: wanted '%s', got '%s'", expectedType, DataType(t))
 	}
 
 	return r.ReadGivenType(DataType(t))
 }
 
+// Offset returns the current reader offset. Inside a compressed or encrypted section the offset is relative to the
+// start of the section, matching the offsets returned by Writer.Write.
+func (r *Reader) Offset() (uint64, error) {
+	if err := r.leaveSections(); err != nil {
+		return 0, err
+	}
+	offset, err := r.r.Seek(0, io.SeekCurrent)
+	return uint64(offset), err
+}
+
+// SeekTo will move the reader to the offset, such as an offset returned by Writer.Write.
+func (r *Reader) SeekTo(offset uint64) error {
+	if err := r.leaveSections(); err != nil {
+		return err
+	}
+	if offset > math.MaxInt64 {
+		return fmt.Errorf("invalid offset")
+	}
+	_, err := r.r.Seek(int64(offset), io.SeekStart)
+	return err
+}
+
+// ReadValueAt will read the value at the offset and return its type and length. Reading continues from the end of
+// the value.
+func (r *Reader) ReadValueAt(offset uint64) (interface{}, DataType, int, error) {
+	if err := r.SeekTo(offset); err != nil {
+		return nil, 0, 0, err
+	}
+
+	val, dataType, err := r.Read(Any)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	end, err := r.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return val, dataType, int(uint64(end) - offset), nil
+}
+
 // ReadRaw reads data into out and returns the number of bytes read into out.
 func (r *Reader) ReadRaw(out []byte) (n int, err error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if err = r.leaveSections(); err != nil {
+		return 0, err
+	}
 	return r.r.Read(out)
 }
 
 // ReadGivenType will read the next value given the type.
 func (r *Reader) ReadGivenType(givenType DataType) (interface{}, DataType, error) {
+	// Each top-level value has its own allocation budget
+	if r.depth == 0 {
+		r.allocated = 0
+	}
+
 	switch givenType {
 	case Byte:
 		val, err := r.readByte()
@@ -204,8 +358,10 @@ func (r *Reader) ReadGivenType(givenType DataType) (interface{}, DataType, error
 		if err != nil {
 			return nil, Bytes, err
 		}
-		buf := make([]byte, len)
-		err = r.readBytes(buf)
+		if err = r.checkLength(len); err != nil {
+			return nil, Bytes, err
+		}
+		buf, err := r.readSlice(len, r.zeroCopy)
 		return buf, Bytes, err
 	case String:
 		return r.readString()
@@ -214,13 +370,22 @@ func (r *Reader) ReadGivenType(givenType DataType) (interface{}, DataType, error
 		if err != nil {
 			return nil, StringSlice, err
 		}
-		sslice := make([]string, lenStrings)
+		if err = r.checkEntries(lenStrings, stringSliceEntrySize); err != nil {
+			return nil, StringSlice, err
+		}
+
+		// Grow as strings are read rather than trusting the length
+		capacity := lenStrings
+		if capacity > maxPreallocatedEntries {
+			capacity = maxPreallocatedEntries
+		}
+		sslice := make([]string, 0, capacity)
 		for i := uint64(0); i < lenStrings; i++ {
 			s, _, err := r.readString()
 			if err != nil {
 				return nil, StringSlice, err
 			}
-			sslice[i] = s
+			sslice = append(sslice, s)
 		}
 		return sslice, StringSlice, err
 	case Integer:
@@ -234,26 +399,107 @@ func (r *Reader) ReadGivenType(givenType DataType) (interface{}, DataType, error
 		return val != 0, givenType, err
 	case KeyValueMap:
 		return r.readKeyValueMap()
+	case CompressedBytes:
+		buf, err := r.readCompressedValue()
+		return buf, Bytes, err
+	case CompressedString:
+		buf, err := r.readCompressedValue()
+		return string(buf), String, err
 	default:
-		panic(fmt.Errorf("cannot read value, unknown data type '%v'", givenType))
+		return nil, givenType, fmt.Errorf("cannot read value, unknown data type '%d'", int(givenType))
 	}
 }
 
-// ReadCompressedBlock will read the next block and decompress it into out.
-func (r *Reader) ReadCompressedBlock(out []byte) (err error) {
-	buf := make([]byte, lz4BlockSize)
-	_, err = r.r.Read(buf)
+// ReadCompressedBlock will read the next block of a compressed section and decompress it into out, returning the
+// number of bytes decompressed. The codec is taken from the section header when reading the first block, and io.EOF
+// is returned once the end of the section has been read.
+func (r *Reader) ReadCompressedBlock(out []byte) (n int, err error) {
+	codec, rawLen, stored, err := r.readStoredBlock()
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if rawLen > len(out) {
+		return 0, fmt.Errorf("compressed block of %d bytes does not fit into buffer of %d bytes", rawLen, len(out))
+	}
+
+	if err = decompressBlock(codec, out[:rawLen], stored); err != nil {
+		return 0, err
 	}
-	_, err = lz4.UncompressBlock(buf, out)
+	return rawLen, nil
+}
+
+// readCompressedValue will read and decompress a whole compressed section.
+func (r *Reader) readCompressedValue() ([]byte, error) {
+	// Use a separate reader so the section state of r is left untouched
+	section := &Reader{r: r.r}
+
+	var buf []byte
+	for {
+		codec, rawLen, stored, err := section.readStoredBlock()
+		if err == io.EOF {
+			return buf, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if r.opts.MaxLength > 0 && uint64(len(buf)+rawLen) > uint64(r.opts.MaxLength) {
+			return nil, &LimitExceededError{Limit: "MaxLength", Value: uint64(len(buf) + rawLen), Max: r.opts.MaxLength}
+		}
+		if err = r.allocate(uint64(rawLen)); err != nil {
+			return nil, err
+		}
+		buf = append(buf, make([]byte, rawLen)...)
+		if err = decompressBlock(codec, buf[len(buf)-rawLen:], stored); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readStoredBlock will read the next block of a compressed section without decompressing it.
+func (r *Reader) readStoredBlock() (codec Codec, rawLen int, stored []byte, err error) {
+	if r.sectionCodec == nil {
+		// Read section header
+		id, err := r.readByte()
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		codec, ok := codecs[id]
+		if !ok {
+			return nil, 0, nil, fmt.Errorf("unknown compression codec '%d'", id)
+		}
+		r.sectionCodec = codec
+	}
+	codec = r.sectionCodec
+
+	len, _, err := r.readUint()
 	if err != nil {
-		return err
+		return nil, 0, nil, err
 	}
-	return nil
+	if len == 0 {
+		r.sectionCodec = nil
+		return nil, 0, nil, io.EOF
+	}
+	if len > uint64(lz4BlockSize) {
+		return nil, 0, nil, fmt.Errorf("corrupt compressed block: uncompressed length %d exceeds block size", len)
+	}
+
+	storedLen, _, err := r.readUint()
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	if storedLen > len {
+		return nil, 0, nil, fmt.Errorf("corrupt compressed block: stored length %d exceeds uncompressed length %d", storedLen, len)
+	}
+
+	stored = make([]byte, storedLen)
+	if err = r.readBytes(stored); err != nil {
+		return nil, 0, nil, err
+	}
+	return codec, int(len), stored, nil
 }
 
-// DecompressToFile will read in the entire reader buffer and decompress it to the specified file.
+// DecompressToFile will read the compressed section and decompress it to the specified file.
 func (r *Reader) DecompressToFile(filePath string) error {
 	f, err := os.Create(filePath)
 	if err != nil {
@@ -261,16 +507,11 @@ func (r *Reader) DecompressToFile(filePath string) error {
 	}
 	defer f.Close()
 
-	zr := lz4.NewReader(r.r)
-	var decomp bytes.Buffer
-	_, err = io.Copy(&decomp, zr)
-	if err != nil {
+	if _, err = io.Copy(f, r.OpenCompressed()); err != nil {
 		return err
 	}
-
-	_, err = f.Write(decomp.Bytes())
-	if err != nil {
+	if err = r.leaveSections(); err != nil {
 		return err
 	}
-	return nil
+	return f.Close()
 }
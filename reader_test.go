@@ -1,6 +1,11 @@
 package cereal
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"gotest.tools/assert"
@@ -78,3 +83,145 @@ func TestReader_Read(t *testing.T) {
 		})
 	}
 }
+
+func TestReader_ReadFloat32(t *testing.T) {
+	buf := new(bytes.Buffer)
+	_, _, err := NewWriterFromBuffer(buf).Write(float32(-0.375))
+	assert.NilError(t, err)
+
+	// Float values are 64-bit, so float32 values read back widened
+	reader := NewReaderFromBuffer(buf.Bytes())
+	val, dataType, err := reader.Read(Any)
+	assert.NilError(t, err)
+	assert.Equal(t, val, float64(-0.375))
+	assert.Equal(t, dataType, Float)
+	_, _, err = reader.Read(Any)
+	assert.Equal(t, err, io.EOF)
+}
+
+func TestReader_ZeroCopy(t *testing.T) {
+	buf := []byte{0x06, 0x03, 0x61, 0x62, 0x63, 0x07, 0x02, 0x64, 0x65}
+
+	reader := NewReaderFromBuffer(buf)
+	reader.SetZeroCopy(true)
+	reader.SetZeroCopyStrings(true)
+	b, _, err := reader.Read(Bytes)
+	assert.NilError(t, err)
+	s, _, err := reader.Read(String)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, b, []byte("abc"))
+	assert.Equal(t, s, "de")
+
+	// Values refer to the buffer
+	buf[2] = 0x7a
+	buf[7] = 0x7a
+	assert.DeepEqual(t, b, []byte("zbc"))
+	assert.Equal(t, s, "ze")
+	assert.Equal(t, cap(b.([]byte)), 3)
+
+	// Values are copied by default
+	reader = NewReaderFromBuffer(buf)
+	b, _, err = reader.Read(Bytes)
+	assert.NilError(t, err)
+	buf[2] = 0x61
+	assert.DeepEqual(t, b, []byte("zbc"))
+}
+
+func TestReader_ZeroCopyTruncated(t *testing.T) {
+	reader := NewReaderFromBuffer([]byte{0x06, 0x05, 0x61, 0x62})
+	reader.SetZeroCopy(true)
+	_, _, err := reader.Read(Bytes)
+	assert.Equal(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestReader_Seek(t *testing.T) {
+	seeker := &byteSeeker{buf: []byte{0x01, 0x02, 0x03}}
+
+	offset, err := seeker.Seek(0, io.SeekEnd)
+	assert.NilError(t, err)
+	assert.Equal(t, offset, int64(3))
+	_, err = seeker.Read(make([]byte, 1))
+	assert.Equal(t, err, io.EOF)
+
+	// The last byte can be sought to and read
+	offset, err = seeker.Seek(-1, io.SeekEnd)
+	assert.NilError(t, err)
+	assert.Equal(t, offset, int64(2))
+	b := make([]byte, 2)
+	n, err := seeker.Read(b)
+	assert.NilError(t, err)
+	assert.Equal(t, n, 1)
+	assert.Equal(t, b[0], byte(0x03))
+
+	offset, err = seeker.Seek(-2, io.SeekCurrent)
+	assert.NilError(t, err)
+	assert.Equal(t, offset, int64(1))
+
+	offset, err = seeker.Seek(10, io.SeekStart)
+	assert.NilError(t, err)
+	assert.Equal(t, offset, int64(10))
+	_, err = seeker.Read(b)
+	assert.Equal(t, err, io.EOF)
+
+	_, err = seeker.Seek(-11, io.SeekCurrent)
+	assert.Error(t, err, "invalid offset")
+}
+
+func TestReader_ReadValueAt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cereal")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	values := []interface{}{"foo", int64(-4), []string{"bar", "baz"}, true}
+	f, err := os.Create(filepath.Join(dir, "values"))
+	assert.NilError(t, err)
+	w := NewWriter(f)
+	var offsets []uint64
+	var lengths []int
+	for _, v := range values {
+		offset, length, err := w.Write(v)
+		assert.NilError(t, err)
+		offsets = append(offsets, offset)
+		lengths = append(lengths, length)
+	}
+	assert.NilError(t, w.Close())
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "values"))
+	assert.NilError(t, err)
+	f, err = os.Open(filepath.Join(dir, "values"))
+	assert.NilError(t, err)
+	defer f.Close()
+
+	for name, reader := range map[string]*Reader{
+		"buffer": NewReaderFromBuffer(content),
+		"file":   NewReader(f),
+	} {
+		t.Run(name, func(t *testing.T) {
+			for i := len(values) - 1; i >= 0; i-- {
+				val, _, length, err := reader.ReadValueAt(offsets[i])
+				assert.NilError(t, err)
+				assert.DeepEqual(t, val, values[i])
+				assert.Equal(t, length, lengths[i])
+
+				offset, err := reader.Offset()
+				assert.NilError(t, err)
+				assert.Equal(t, offset, offsets[i]+uint64(lengths[i]))
+			}
+
+			assert.NilError(t, reader.SeekTo(offsets[2]))
+			val, _, err := reader.Read(StringSlice)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, val, values[2])
+			val, _, err = reader.Read(Boolean)
+			assert.NilError(t, err)
+			assert.Equal(t, val, true)
+
+			// Offset is still available at the end of the input
+			offset, err := reader.Offset()
+			assert.NilError(t, err)
+			assert.Equal(t, offset, uint64(len(content)))
+			_, _, err = reader.Read(Any)
+			assert.Equal(t, err, io.EOF)
+		})
+	}
+}
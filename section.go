@@ -0,0 +1,137 @@
+package cereal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// blockSource returns the decoded blocks of a section in order, then io.EOF once the end of the section is read.
+type blockSource interface {
+	next() ([]byte, error)
+}
+
+// sectionReader reads the decoded content of a section made up of blocks, such as a compressed section. Values may
+// span block boundaries.
+type sectionReader struct {
+	parent io.ReadSeeker
+	blocks blockSource
+	buf    []byte
+	pos    int
+	base   int64
+	ended  bool
+	err    error
+}
+
+// openSection will switch the Reader to reading the decoded content of a section until it has been fully read.
+func (r *Reader) openSection(blocks blockSource) *sectionReader {
+	sr := &sectionReader{
+		parent: r.r,
+		blocks: blocks,
+	}
+	r.r = sr
+	return sr
+}
+
+// fill will load the next block, returning io.EOF at the end of the section.
+func (sr *sectionReader) fill() error {
+	if sr.ended {
+		return io.EOF
+	}
+	if sr.err != nil {
+		return sr.err
+	}
+
+	block, err := sr.blocks.next()
+	if err == io.EOF {
+		sr.ended = true
+	} else if err != nil {
+		sr.err = err
+	}
+	if err != nil {
+		return err
+	}
+
+	sr.base += int64(sr.pos)
+	sr.buf = append(sr.buf[:0], block...)
+	sr.pos = 0
+	return nil
+}
+
+func (sr *sectionReader) Read(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for sr.pos == len(sr.buf) {
+		if err = sr.fill(); err != nil {
+			return 0, err
+		}
+	}
+
+	n = copy(p, sr.buf[sr.pos:])
+	sr.pos += n
+	return n, nil
+}
+
+func (sr *sectionReader) ReadByte() (byte, error) {
+	for sr.pos == len(sr.buf) {
+		if err := sr.fill(); err != nil {
+			return 0, err
+		}
+	}
+
+	c := sr.buf[sr.pos]
+	sr.pos++
+	return c, nil
+}
+
+// Seek only supports moving relative to the current position within the buffered data.
+func (sr *sectionReader) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekCurrent {
+		return 0, fmt.Errorf("section only supports seeking from the current offset")
+	}
+
+	pos := int64(sr.pos) + offset
+	if pos < 0 || pos > int64(len(sr.buf)) {
+		return 0, fmt.Errorf("invalid offset")
+	}
+	sr.pos = int(pos)
+	return sr.base + pos, nil
+}
+
+// done reports whether the whole section has been read.
+func (sr *sectionReader) done() (bool, error) {
+	for sr.pos == len(sr.buf) {
+		err := sr.fill()
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// leaveSections will return the Reader to the data after any sections that have been fully read.
+func (r *Reader) leaveSections() error {
+	for {
+		sr, ok := r.r.(*sectionReader)
+		if !ok {
+			return nil
+		}
+
+		done, err := sr.done()
+		if err != nil || !done {
+			return err
+		}
+		r.r = sr.parent
+	}
+}
+
+func writeUvarint(w io.Writer, v uint64) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	size := binary.PutUvarint(buf, v)
+	_, err := w.Write(buf[:size])
+	return err
+}
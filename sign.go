@@ -0,0 +1,243 @@
+package cereal
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// Signed content is followed by a trailer:
+//
+//	[]byte   signature
+//	byte     signature algorithm
+//	[4]byte  signatureMagic
+//
+// HMAC-SHA256 signatures are computed over the content. Ed25519 signatures are computed over the SHA-512 digest of
+// the content so that it can be signed while it is being written.
+
+const (
+	hmacSignature    byte = 1
+	ed25519Signature byte = 2
+)
+
+var signatureMagic = []byte("CSIG")
+
+var (
+	// ErrUnsigned is returned by Verify when the content does not end with a signature.
+	ErrUnsigned = errors.New("content is not signed")
+
+	// ErrInvalidSignature is returned by Verify when the signature does not match the content or key.
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// maxTrailerLen is the length of the largest signature trailer.
+var maxTrailerLen = ed25519.SignatureSize + 1 + len(signatureMagic)
+
+// signatureSize returns the length of the signature for the algorithm.
+func signatureSize(algorithm byte) int {
+	switch algorithm {
+	case hmacSignature:
+		return sha256.Size
+	case ed25519Signature:
+		return ed25519.SignatureSize
+	}
+	return -1
+}
+
+// signingHash returns the algorithm and the hash of the content to sign or verify with the key.
+func signingHash(key interface{}) (byte, hash.Hash, error) {
+	switch key := key.(type) {
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return ed25519Signature, sha512.New(), nil
+	case []byte:
+		return hmacSignature, hmac.New(sha256.New, key), nil
+	}
+	return 0, nil, fmt.Errorf("unsupported signing key type '%T'", key)
+}
+
+// SetSigningKey will sign everything written when the writer is closed. The key is either a []byte secret for an
+// HMAC-SHA256 signature or an ed25519.PrivateKey. It must be called before anything is written.
+func (w *Writer) SetSigningKey(key interface{}) error {
+	if w.w.Count() != 0 || w.sections != 0 {
+		return fmt.Errorf("signing key must be set before writing")
+	}
+
+	algorithm, h, err := signingHash(key)
+	if err != nil {
+		return err
+	}
+	if privateKey, ok := key.(ed25519.PrivateKey); algorithm == ed25519Signature && !ok {
+		return fmt.Errorf("signing key must be an ed25519.PrivateKey, not '%T'", key)
+	} else if ok && len(privateKey) != ed25519.PrivateKeySize {
+		return fmt.Errorf("invalid ed25519 private key size %d", len(privateKey))
+	}
+
+	w.signingKey = key
+	w.w.sum = h
+	return nil
+}
+
+// writeSignature will write the signature trailer over everything written so far.
+func (w *Writer) writeSignature() error {
+	key := w.signingKey
+	w.signingKey = nil
+
+	algorithm, _, _ := signingHash(key)
+	sum := w.w.sum.Sum(nil)
+	w.w.sum = nil
+
+	signature := sum
+	if algorithm == ed25519Signature {
+		signature = ed25519.Sign(key.(ed25519.PrivateKey), sum)
+	}
+
+	// Write trailer
+	if _, err := w.w.Write(signature); err != nil {
+		return err
+	}
+	if err := w.w.WriteByte(algorithm); err != nil {
+		return err
+	}
+	_, err := w.w.Write(signatureMagic)
+	return err
+}
+
+// Verify will check the signature at the end of the content using the key, which is either the []byte secret of an
+// HMAC-SHA256 signature or an ed25519.PublicKey. If the content is unsigned, signed with a different algorithm or the
+// signature does not match then an error is returned and all further reads fail with that error. Once verified, reads
+// end at the signature trailer.
+func (r *Reader) Verify(key interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	if err := r.leaveSections(); err != nil {
+		return err
+	}
+	if _, ok := r.r.(*sectionReader); ok {
+		return fmt.Errorf("cannot verify signature while a section is open")
+	}
+	if err := r.verify(key); err != nil {
+		r.err = err
+		return err
+	}
+	return nil
+}
+
+func (r *Reader) verify(key interface{}) error {
+	algorithm, h, err := signingHash(key)
+	if err != nil {
+		return err
+	}
+
+	offset, err := r.r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err = r.r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	// Hash everything except the largest possible trailer, which is kept aside
+	tail := make([]byte, 0, 2*maxTrailerLen)
+	buf := make([]byte, 32<<10)
+	var total int64
+	for {
+		n, err := r.r.Read(buf)
+		total += int64(n)
+		if n > 0 {
+			tail = append(tail, buf[:n]...)
+			if len(tail) > maxTrailerLen {
+				h.Write(tail[:len(tail)-maxTrailerLen])
+				tail = append(tail[:0], tail[len(tail)-maxTrailerLen:]...)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, err = r.r.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	// Read trailer
+	end := len(tail) - len(signatureMagic) - 1
+	if end < 0 || string(tail[end+1:]) != string(signatureMagic) {
+		return ErrUnsigned
+	}
+	if tail[end] != algorithm {
+		return fmt.Errorf("%v: content signed with algorithm '%d'", ErrInvalidSignature, tail[end])
+	}
+	start := end - signatureSize(algorithm)
+	if start < 0 {
+		return ErrUnsigned
+	}
+	signature := tail[start:end]
+	h.Write(tail[:start])
+
+	switch algorithm {
+	case hmacSignature:
+		if !hmac.Equal(h.Sum(nil), signature) {
+			return ErrInvalidSignature
+		}
+	case ed25519Signature:
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok || len(publicKey) != ed25519.PublicKeySize || !ed25519.Verify(publicKey, h.Sum(nil), signature) {
+			return ErrInvalidSignature
+		}
+	}
+
+	// End reads at the trailer
+	trailerStart := total - int64(len(tail)-start)
+	if b, ok := r.r.(*byteSeeker); ok {
+		b.buf = b.buf[:trailerStart]
+	} else {
+		r.r = &contentReader{r: r.r, offset: offset, end: trailerStart}
+	}
+	return nil
+}
+
+// contentReader ends reads of signed content at its trailer.
+type contentReader struct {
+	r      io.ReadSeeker
+	offset int64
+	end    int64
+}
+
+func (c *contentReader) Read(p []byte) (n int, err error) {
+	if c.offset >= c.end {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > c.end-c.offset {
+		p = p[:c.end-c.offset]
+	}
+	n, err = c.r.Read(p)
+	c.offset += int64(n)
+	return n, err
+}
+
+func (c *contentReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += c.offset
+	case io.SeekEnd:
+		offset += c.end
+	default:
+		return 0, fmt.Errorf("invalid whence")
+	}
+
+	offset, err := c.r.Seek(offset, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	c.offset = offset
+	return offset, nil
+}
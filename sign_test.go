@@ -0,0 +1,170 @@
+package cereal
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func writeSigned(t *testing.T, key interface{}) []byte {
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	assert.NilError(t, w.SetSigningKey(key))
+
+	_, _, err := w.Write(map[string]interface{}{"name": "signed", "n": int64(3)})
+	assert.NilError(t, err)
+	_, _, err = w.Write(bytes.Repeat([]byte{0xAB}, 100000))
+	assert.NilError(t, err)
+	assert.NilError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestSign_Verify(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.New(rand.NewSource(1)))
+	assert.NilError(t, err)
+	otherPublicKey, _, err := ed25519.GenerateKey(rand.New(rand.NewSource(2)))
+	assert.NilError(t, err)
+
+	hmacSigned := writeSigned(t, []byte("secret"))
+	ed25519Signed := writeSigned(t, privateKey)
+
+	unsigned := new(bytes.Buffer)
+	_, _, err = NewWriterFromBuffer(unsigned).Write("unsigned")
+	assert.NilError(t, err)
+
+	tampered := append([]byte{}, hmacSigned...)
+	tampered[5] ^= 0x01
+
+	tests := map[string]struct {
+		buf []byte
+		key interface{}
+		err string
+	}{
+		"hmac":                {buf: hmacSigned, key: []byte("secret")},
+		"ed25519":             {buf: ed25519Signed, key: publicKey},
+		"hmac wrong key":      {buf: hmacSigned, key: []byte("guess"), err: "invalid signature"},
+		"ed25519 wrong key":   {buf: ed25519Signed, key: otherPublicKey, err: "invalid signature"},
+		"tampered":            {buf: tampered, key: []byte("secret"), err: "invalid signature"},
+		"truncated":           {buf: hmacSigned[:len(hmacSigned)-1], key: []byte("secret"), err: "content is not signed"},
+		"unsigned":            {buf: unsigned.Bytes(), key: []byte("secret"), err: "content is not signed"},
+		"algorithm confusion": {buf: ed25519Signed, key: []byte(publicKey), err: "invalid signature: content signed with algorithm '2'"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := NewReaderFromBuffer(test.buf)
+			err := r.Verify(test.key)
+			if test.err != "" {
+				assert.Error(t, err, test.err)
+				_, _, err = r.Read(Any)
+				assert.Error(t, err, test.err)
+				return
+			}
+
+			assert.NilError(t, err)
+			val, _, err := r.Read(KeyValueMap)
+			assert.NilError(t, err)
+			assert.DeepEqual(t, val, map[string]interface{}{"name": "signed", "n": int64(3)})
+
+			// Reads end at the trailer
+			_, _, err = r.Read(Bytes)
+			assert.NilError(t, err)
+			_, _, err = r.Read(Any)
+			assert.Equal(t, err, io.EOF)
+		})
+	}
+}
+
+func TestSign_File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cereal")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+
+	f, err := os.Create(filepath.Join(dir, "signed"))
+	assert.NilError(t, err)
+	w := NewWriter(f)
+	assert.NilError(t, w.SetSigningKey([]byte("secret")))
+	_, _, err = w.Write("first")
+	assert.NilError(t, err)
+	_, _, err = w.Write("second")
+	assert.NilError(t, err)
+	assert.NilError(t, w.Close())
+
+	f, err = os.Open(filepath.Join(dir, "signed"))
+	assert.NilError(t, err)
+	defer f.Close()
+
+	// Verification keeps the current position
+	r := NewReader(f)
+	val, _, err := r.Read(String)
+	assert.NilError(t, err)
+	assert.Equal(t, val, "first")
+	assert.NilError(t, r.Verify([]byte("secret")))
+	val, _, err = r.Read(String)
+	assert.NilError(t, err)
+	assert.Equal(t, val, "second")
+	_, _, err = r.Read(Any)
+	assert.Equal(t, err, io.EOF)
+}
+
+func TestSign_VerifyOpenSection(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	assert.NilError(t, w.SetSigningKey([]byte("secret")))
+	cw := w.BeginCompressed(LZ4)
+	_, _, err := w.Write("first")
+	assert.NilError(t, err)
+	_, _, err = w.Write("second")
+	assert.NilError(t, err)
+	assert.NilError(t, cw.Close())
+	assert.NilError(t, w.Close())
+
+	r := NewReaderFromBuffer(buf.Bytes())
+	r.OpenCompressed()
+	val, _, err := r.Read(String)
+	assert.NilError(t, err)
+	assert.Equal(t, val, "first")
+	assert.Error(t, r.Verify([]byte("secret")), "cannot verify signature while a section is open")
+
+	// Verification is possible once the section has been read
+	val, _, err = r.Read(String)
+	assert.NilError(t, err)
+	assert.Equal(t, val, "second")
+	assert.NilError(t, r.Verify([]byte("secret")))
+	_, _, err = r.Read(Any)
+	assert.Equal(t, err, io.EOF)
+}
+
+func TestSign_CloseOpenSection(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	assert.NilError(t, w.SetSigningKey([]byte("secret")))
+	cw := w.BeginCompressed(LZ4)
+	ew, err := w.BeginEncrypted(testKey)
+	assert.NilError(t, err)
+	_, _, err = w.Write("value")
+	assert.NilError(t, err)
+	assert.Error(t, w.Close(), "cannot close writer while a section is open")
+
+	// The writer can be closed once its sections are
+	assert.NilError(t, ew.Close())
+	assert.Error(t, w.Close(), "cannot close writer while a section is open")
+	assert.NilError(t, cw.Close())
+	assert.NilError(t, w.Close())
+	assert.NilError(t, NewReaderFromBuffer(buf.Bytes()).Verify([]byte("secret")))
+}
+
+func TestSign_SetSigningKeyAfterWrite(t *testing.T) {
+	w := NewWriterFromBuffer(new(bytes.Buffer))
+	_, _, err := w.Write(true)
+	assert.NilError(t, err)
+	assert.Error(t, w.SetSigningKey([]byte("secret")), "signing key must be set before writing")
+	assert.ErrorContains(t, NewWriterFromBuffer(new(bytes.Buffer)).SetSigningKey("secret"), "unsupported signing key type")
+	assert.ErrorContains(t, NewWriterFromBuffer(new(bytes.Buffer)).SetSigningKey(ed25519.PublicKey{}), "must be an ed25519.PrivateKey")
+}
@@ -0,0 +1,101 @@
+package cereal
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+
+	"github.com/bt/cereal/internal/convert"
+)
+
+// Value stores a value in a database column as cereal encoded bytes. A nil value is stored as NULL.
+type Value struct {
+	V interface{}
+}
+
+// Value will encode the value for the database driver.
+func (v Value) Value() (driver.Value, error) {
+	if v.V == nil {
+		return nil, nil
+	}
+	return encodeValue(v.V)
+}
+
+// Scan will decode the column value, setting V to nil for NULL.
+func (v *Value) Scan(src interface{}) error {
+	val, err := scanValue(src)
+	if err != nil {
+		return err
+	}
+	v.V = val
+	return nil
+}
+
+// TypedValue stores a value of type T in a database column as cereal encoded bytes. Valid is false for NULL.
+type TypedValue[T any] struct {
+	V     T
+	Valid bool
+}
+
+// Value will encode the value for the database driver.
+func (v TypedValue[T]) Value() (driver.Value, error) {
+	if !v.Valid {
+		return nil, nil
+	}
+	return encodeValue(v.V)
+}
+
+// Scan will decode the column value, which must decode to T, or a number which fits T, unless it is NULL.
+func (v *TypedValue[T]) Scan(src interface{}) error {
+	val, err := scanValue(src)
+	if err != nil {
+		return err
+	}
+
+	var zero T
+	if val == nil {
+		v.V, v.Valid = zero, false
+		return nil
+	}
+	if t, ok := val.(T); ok {
+		v.V, v.Valid = t, true
+		return nil
+	}
+
+	// Convert numbers, which are read as 64-bit, to narrower types
+	target := reflect.ValueOf(&v.V).Elem()
+	if !convert.Number(target, val) {
+		return fmt.Errorf("cannot scan %T value into %T", val, zero)
+	}
+	v.Valid = true
+	return nil
+}
+
+// encodeValue will return the encoding of the value.
+func encodeValue(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if _, _, err := NewWriterFromBuffer(buf).Write(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// scanValue will decode a column value, which is nil for NULL.
+func scanValue(src interface{}) (interface{}, error) {
+	var buf []byte
+	switch src := src.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		buf = src
+	case string:
+		buf = []byte(src)
+	default:
+		return nil, fmt.Errorf("cannot scan %T column into cereal value", src)
+	}
+
+	// Values are copied out of the buffer, which the driver may reuse
+	val, _, err := NewReaderFromBuffer(buf).Read(Any)
+	return val, err
+}
@@ -0,0 +1,111 @@
+package cereal
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"math"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+var (
+	_ sql.Scanner   = &Value{}
+	_ driver.Valuer = Value{}
+	_ sql.Scanner   = &TypedValue[string]{}
+	_ driver.Valuer = TypedValue[string]{}
+)
+
+func TestValue(t *testing.T) {
+	in := Value{V: map[string]interface{}{"tags": []string{"a"}, "n": int64(1)}}
+	col, err := in.Value()
+	assert.NilError(t, err)
+	assert.Assert(t, driver.IsValue(col))
+
+	// Drivers may reuse the buffer after scanning
+	buf := col.([]byte)
+	var out Value
+	assert.NilError(t, out.Scan(buf))
+	for i := range buf {
+		buf[i] = 0
+	}
+	assert.DeepEqual(t, out.V, in.V)
+
+	assert.NilError(t, out.Scan("\x07\x02hi"))
+	assert.Equal(t, out.V, "hi")
+
+	col, err = Value{}.Value()
+	assert.NilError(t, err)
+	assert.Equal(t, col, nil)
+	assert.NilError(t, out.Scan(nil))
+	assert.Equal(t, out.V, nil)
+
+	_, err = Value{V: struct{}{}}.Value()
+	assert.ErrorContains(t, err, "cannot write value, unknown data type")
+	assert.Error(t, out.Scan(int64(1)), "cannot scan int64 column into cereal value")
+	assert.Error(t, out.Scan([]byte{byte(String), 5, 'a'}), "unexpected EOF")
+}
+
+func TestTypedValue(t *testing.T) {
+	in := TypedValue[[]string]{V: []string{"a", "b"}, Valid: true}
+	col, err := in.Value()
+	assert.NilError(t, err)
+
+	var out TypedValue[[]string]
+	assert.NilError(t, out.Scan(col))
+	assert.DeepEqual(t, out, in)
+
+	assert.NilError(t, out.Scan(nil))
+	assert.Assert(t, !out.Valid)
+	assert.Assert(t, out.V == nil)
+
+	col, err = TypedValue[int64]{V: 5}.Value()
+	assert.NilError(t, err)
+	assert.Equal(t, col, nil)
+
+	col, err = TypedValue[int64]{V: 5, Valid: true}.Value()
+	assert.NilError(t, err)
+	assert.Error(t, out.Scan(col), "cannot scan int64 value into []string")
+
+	var dynamic TypedValue[interface{}]
+	assert.NilError(t, dynamic.Scan(col))
+	assert.Equal(t, dynamic.V, int64(5))
+}
+
+func TestTypedValue_Numbers(t *testing.T) {
+	col, err := TypedValue[int]{V: -7, Valid: true}.Value()
+	assert.NilError(t, err)
+	var i TypedValue[int]
+	assert.NilError(t, i.Scan(col))
+	assert.Equal(t, i, TypedValue[int]{V: -7, Valid: true})
+
+	col, err = TypedValue[int32]{V: math.MinInt32, Valid: true}.Value()
+	assert.NilError(t, err)
+	var i32 TypedValue[int32]
+	assert.NilError(t, i32.Scan(col))
+	assert.Equal(t, i32, TypedValue[int32]{V: math.MinInt32, Valid: true})
+
+	col, err = TypedValue[uint8]{V: 255, Valid: true}.Value()
+	assert.NilError(t, err)
+	var u8 TypedValue[uint8]
+	assert.NilError(t, u8.Scan(col))
+	assert.Equal(t, u8, TypedValue[uint8]{V: 255, Valid: true})
+
+	col, err = TypedValue[float32]{V: 1.25, Valid: true}.Value()
+	assert.NilError(t, err)
+	var f32 TypedValue[float32]
+	assert.NilError(t, f32.Scan(col))
+	assert.Equal(t, f32, TypedValue[float32]{V: 1.25, Valid: true})
+
+	// Numbers which do not fit are refused
+	col, err = TypedValue[int64]{V: 256, Valid: true}.Value()
+	assert.NilError(t, err)
+	assert.Error(t, u8.Scan(col), "cannot scan int64 value into uint8")
+	col, err = TypedValue[int64]{V: -1, Valid: true}.Value()
+	assert.NilError(t, err)
+	assert.Error(t, u8.Scan(col), "cannot scan int64 value into uint8")
+	col, err = TypedValue[float64]{V: math.MaxFloat64, Valid: true}.Value()
+	assert.NilError(t, err)
+	assert.Error(t, f32.Scan(col), "cannot scan float64 value into float32")
+	assert.Error(t, i.Scan(col), "cannot scan float64 value into int")
+}
@@ -0,0 +1,125 @@
+package sstable
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/bt/cereal"
+)
+
+// Builder writes a table from keys added in ascending order.
+type Builder struct {
+	w         *cereal.Writer
+	codec     cereal.Codec
+	blockSize int
+	index     []blockHandle
+
+	// Block being written
+	block      *blockHandle
+	blockStart uint64
+	section    io.WriteCloser
+
+	lastKey string
+	hasKey  bool
+}
+
+// NewBuilder will return a new builder which writes the table to the writer.
+func NewBuilder(w *cereal.Writer) *Builder {
+	return &Builder{
+		w:         w,
+		blockSize: defaultBlockSize,
+	}
+}
+
+// SetBlockSize will set the uncompressed size at which data blocks are finished.
+func (b *Builder) SetBlockSize(n int) {
+	b.blockSize = n
+}
+
+// SetCompression will compress data blocks with the codec. A nil codec leaves blocks uncompressed.
+func (b *Builder) SetCompression(codec cereal.Codec) {
+	b.codec = codec
+}
+
+// Add will write the value under the key, which must be greater than the previously added key.
+func (b *Builder) Add(key string, value interface{}) error {
+	if b.hasKey && key <= b.lastKey {
+		return fmt.Errorf("key '%s' added after '%s', keys must be added in ascending order", key, b.lastKey)
+	}
+
+	if b.block == nil {
+		b.startBlock(key)
+	}
+
+	// Write entry
+	if _, _, err := b.w.Write(key); err != nil {
+		return err
+	}
+	if _, _, err := b.w.Write(value); err != nil {
+		return err
+	}
+	b.block.entries++
+	b.lastKey = key
+	b.hasKey = true
+
+	if b.w.Offset()-b.blockStart >= uint64(b.blockSize) {
+		return b.finishBlock()
+	}
+	return nil
+}
+
+func (b *Builder) startBlock(firstKey string) {
+	b.block = &blockHandle{
+		firstKey: firstKey,
+		offset:   b.w.Offset(),
+	}
+	if b.codec != nil {
+		b.section = b.w.BeginCompressed(b.codec)
+	}
+	b.blockStart = b.w.Offset()
+}
+
+func (b *Builder) finishBlock() error {
+	if b.section != nil {
+		if err := b.section.Close(); err != nil {
+			return err
+		}
+		b.section = nil
+	}
+	b.index = append(b.index, *b.block)
+	b.block = nil
+	return nil
+}
+
+// Close will finish the last block and write the index and footer. The writer is left open for the caller to close.
+func (b *Builder) Close() error {
+	if b.block != nil {
+		if err := b.finishBlock(); err != nil {
+			return err
+		}
+	}
+	indexOffset := b.w.Offset()
+
+	// Write index
+	if _, _, err := b.w.Write(uint64(len(b.index))); err != nil {
+		return err
+	}
+	for _, h := range b.index {
+		for _, v := range []interface{}{h.firstKey, h.offset, h.entries} {
+			if _, _, err := b.w.Write(v); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Write footer
+	footer := make([]byte, footerLen)
+	binary.BigEndian.PutUint64(footer, indexOffset)
+	if b.codec != nil {
+		footer[8] = 1
+	}
+	copy(footer[9:], magic)
+	_, err := b.w.WriteRaw(footer)
+	return err
+}
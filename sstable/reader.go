@@ -0,0 +1,231 @@
+package sstable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/bt/cereal"
+)
+
+// Reader looks up values in a table.
+type Reader struct {
+	r          io.ReadSeeker
+	compressed bool
+	index      []blockHandle
+}
+
+// NewReader will read the index of the table and return a reader for it.
+func NewReader(r io.ReadSeeker) (*Reader, error) {
+	// Read footer
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if end < int64(footerLen) {
+		return nil, fmt.Errorf("not a table: too short")
+	}
+	footer := make([]byte, footerLen)
+	if _, err = r.Seek(end-int64(footerLen), io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err = io.ReadFull(r, footer); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(footer[9:], magic) {
+		return nil, fmt.Errorf("not a table: invalid magic")
+	}
+
+	t := &Reader{
+		r:          r,
+		compressed: footer[8] == 1,
+	}
+	if t.index, err = t.readIndex(binary.BigEndian.Uint64(footer)); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// NewReaderFromBuffer will return a reader for the table held in the buffer.
+func NewReaderFromBuffer(buf []byte) (*Reader, error) {
+	return NewReader(bytes.NewReader(buf))
+}
+
+func (t *Reader) readIndex(offset uint64) ([]blockHandle, error) {
+	r := cereal.NewReader(t.r)
+	if err := r.SeekTo(offset); err != nil {
+		return nil, err
+	}
+
+	len, _, err := r.Read(cereal.UnsignedInteger)
+	if err != nil {
+		return nil, err
+	}
+	var index []blockHandle
+	for i := uint64(0); i < len.(uint64); i++ {
+		var h blockHandle
+		for _, v := range []interface{}{&h.firstKey, &h.offset, &h.entries} {
+			val, _, err := r.Read(cereal.Any)
+			if err != nil {
+				return nil, err
+			}
+			switch v := v.(type) {
+			case *string:
+				s, ok := val.(string)
+				if !ok {
+					return nil, fmt.Errorf("corrupt index: expected key, got %T", val)
+				}
+				*v = s
+			case *uint64:
+				n, ok := val.(uint64)
+				if !ok {
+					return nil, fmt.Errorf("corrupt index: expected uint, got %T", val)
+				}
+				*v = n
+			}
+		}
+		index = append(index, h)
+	}
+	return index, nil
+}
+
+// readBlock will read the entries of the ith data block.
+func (t *Reader) readBlock(i int) ([]entry, error) {
+	h := t.index[i]
+	r := cereal.NewReader(t.r)
+	if err := r.SeekTo(h.offset); err != nil {
+		return nil, err
+	}
+	if t.compressed {
+		r.OpenCompressed()
+	}
+
+	// Grow as entries are read rather than trusting the index
+	capacity := h.entries
+	if capacity > maxPreallocatedEntries {
+		capacity = maxPreallocatedEntries
+	}
+	entries := make([]entry, 0, capacity)
+	for j := uint64(0); j < h.entries; j++ {
+		key, _, err := r.Read(cereal.String)
+		if err != nil {
+			return nil, err
+		}
+		value, _, err := r.Read(cereal.Any)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key: key.(string), value: value})
+	}
+	return entries, nil
+}
+
+// findBlock returns the index of the block which may contain the key, or -1 if the key is before the first block.
+func (t *Reader) findBlock(key string) int {
+	return sort.Search(len(t.index), func(i int) bool {
+		return t.index[i].firstKey > key
+	}) - 1
+}
+
+// Get will return the value stored under the key, or ErrNotFound.
+func (t *Reader) Get(key string) (interface{}, error) {
+	i := t.findBlock(key)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+
+	entries, err := t.readBlock(i)
+	if err != nil {
+		return nil, err
+	}
+	j := sort.Search(len(entries), func(j int) bool {
+		return entries[j].key >= key
+	})
+	if j == len(entries) || entries[j].key != key {
+		return nil, ErrNotFound
+	}
+	return entries[j].value, nil
+}
+
+// Range will return an iterator over the keys from start up to but excluding limit, in ascending order. An empty limit
+// iterates to the end of the table.
+func (t *Reader) Range(start, limit string) *Iterator {
+	block := t.findBlock(start)
+	if block < 0 {
+		block = 0
+	}
+	return &Iterator{
+		t:     t,
+		block: block,
+		start: start,
+		limit: limit,
+	}
+}
+
+// Iterator iterates over the entries of a table in key order.
+type Iterator struct {
+	t       *Reader
+	block   int
+	entries []entry
+	pos     int
+	loaded  bool
+	start   string
+	limit   string
+	current entry
+	err     error
+	done    bool
+}
+
+// Next will advance to the next entry, returning false when there are no more entries or an error occurred.
+func (it *Iterator) Next() bool {
+	for !it.done {
+		if !it.loaded {
+			if it.block >= len(it.t.index) {
+				it.done = true
+				break
+			}
+			if it.entries, it.err = it.t.readBlock(it.block); it.err != nil {
+				it.done = true
+				break
+			}
+			it.pos = 0
+			it.loaded = true
+		}
+
+		if it.pos == len(it.entries) {
+			it.block++
+			it.loaded = false
+			continue
+		}
+
+		e := it.entries[it.pos]
+		it.pos++
+		if e.key < it.start {
+			continue
+		}
+		if it.limit != "" && e.key >= it.limit {
+			it.done = true
+			break
+		}
+		it.current = e
+		return true
+	}
+	return false
+}
+
+// Key returns the key of the current entry.
+func (it *Iterator) Key() string {
+	return it.current.key
+}
+
+// Value returns the value of the current entry.
+func (it *Iterator) Value() interface{} {
+	return it.current.value
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (it *Iterator) Err() error {
+	return it.err
+}
@@ -0,0 +1,41 @@
+// Package sstable implements sorted string tables: immutable files of cereal-encoded values looked up by key.
+//
+// A table is a sequence of data blocks, each holding entries in ascending key order as a String key followed by the
+// value. Blocks are optionally written as compressed sections. The blocks are followed by a sparse index, holding the
+// first key, offset and number of entries of each block, and a footer:
+//
+//	[8]byte  big-endian offset of the index
+//	byte     1 if blocks are compressed, otherwise 0
+//	[4]byte  magic
+package sstable
+
+import (
+	"errors"
+)
+
+var magic = []byte("SSTB")
+
+// footerLen is the length of the footer pointing at the index.
+var footerLen = 8 + 1 + len(magic)
+
+// defaultBlockSize is the uncompressed size at which a data block is finished.
+var defaultBlockSize = 4 << 10
+
+// maxPreallocatedEntries is the most entries of a block allocated before they are read.
+const maxPreallocatedEntries = 1024
+
+// ErrNotFound is returned by Get when the key is not in the table.
+var ErrNotFound = errors.New("key not found")
+
+// blockHandle locates a data block.
+type blockHandle struct {
+	firstKey string
+	offset   uint64
+	entries  uint64
+}
+
+// entry is a key and value read from a data block.
+type entry struct {
+	key   string
+	value interface{}
+}
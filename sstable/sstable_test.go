@@ -0,0 +1,133 @@
+package sstable
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bt/cereal"
+	"gotest.tools/assert"
+)
+
+func buildTable(t *testing.T, codec cereal.Codec, n int) []byte {
+	buf := new(bytes.Buffer)
+	b := NewBuilder(cereal.NewWriterFromBuffer(buf))
+	b.SetBlockSize(256)
+	b.SetCompression(codec)
+	for i := 0; i < n; i++ {
+		assert.NilError(t, b.Add(fmt.Sprintf("key%04d", i*2), map[string]interface{}{"n": int64(i)}))
+	}
+	assert.NilError(t, b.Close())
+	return buf.Bytes()
+}
+
+func TestReader_Get(t *testing.T) {
+	for name, codec := range map[string]cereal.Codec{"uncompressed": nil, "lz4": cereal.LZ4, "deflate": cereal.Deflate} {
+		t.Run(name, func(t *testing.T) {
+			r, err := NewReaderFromBuffer(buildTable(t, codec, 500))
+			assert.NilError(t, err)
+			assert.Assert(t, len(r.index) > 1)
+
+			for i := 0; i < 500; i++ {
+				val, err := r.Get(fmt.Sprintf("key%04d", i*2))
+				assert.NilError(t, err)
+				assert.DeepEqual(t, val, map[string]interface{}{"n": int64(i)})
+			}
+
+			for _, key := range []string{"", "a", "key0001", "key0999", "key1000", "z"} {
+				_, err = r.Get(key)
+				assert.Equal(t, err, ErrNotFound, key)
+			}
+		})
+	}
+}
+
+func TestReader_Range(t *testing.T) {
+	r, err := NewReaderFromBuffer(buildTable(t, cereal.LZ4, 500))
+	assert.NilError(t, err)
+
+	tests := []struct {
+		start, limit string
+		first, count int
+	}{
+		{"", "", 0, 500},
+		{"key0101", "key0200", 51, 49},
+		{"key0100", "key0101", 50, 1},
+		{"key0998", "", 499, 1},
+		{"z", "", 0, 0},
+		{"key0500", "key0100", 0, 0},
+	}
+	for _, tt := range tests {
+		it := r.Range(tt.start, tt.limit)
+		i := tt.first
+		for it.Next() {
+			assert.Equal(t, it.Key(), fmt.Sprintf("key%04d", i*2))
+			assert.DeepEqual(t, it.Value(), map[string]interface{}{"n": int64(i)})
+			i++
+		}
+		assert.NilError(t, it.Err())
+		assert.Equal(t, i-tt.first, tt.count, "%s to %s", tt.start, tt.limit)
+	}
+}
+
+func TestBuilder_Order(t *testing.T) {
+	b := NewBuilder(cereal.NewWriterFromBuffer(new(bytes.Buffer)))
+	assert.NilError(t, b.Add("b", "1"))
+	assert.Error(t, b.Add("a", "2"), "key 'a' added after 'b', keys must be added in ascending order")
+	assert.Error(t, b.Add("b", "2"), "key 'b' added after 'b', keys must be added in ascending order")
+	assert.NilError(t, b.Close())
+}
+
+func TestReader_Empty(t *testing.T) {
+	r, err := NewReaderFromBuffer(buildTable(t, nil, 0))
+	assert.NilError(t, err)
+	_, err = r.Get("a")
+	assert.Equal(t, err, ErrNotFound)
+	assert.Assert(t, !r.Range("", "").Next())
+
+	_, err = NewReaderFromBuffer([]byte("not a table at all"))
+	assert.Error(t, err, "not a table: invalid magic")
+}
+
+func TestBuilder_Close(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "table"))
+	assert.NilError(t, err)
+	w := cereal.NewWriter(f)
+	b := NewBuilder(w)
+	assert.NilError(t, b.Add("a", "1"))
+	assert.NilError(t, b.Close())
+
+	// The writer belongs to the caller
+	_, err = f.Stat()
+	assert.NilError(t, err)
+	assert.NilError(t, w.Close())
+}
+
+func TestReader_CorruptEntryCount(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := cereal.NewWriterFromBuffer(buf)
+	for _, v := range []interface{}{"a", "1"} {
+		_, _, err := w.Write(v)
+		assert.NilError(t, err)
+	}
+
+	// The index claims the block holds far more entries than it does
+	indexOffset := w.Offset()
+	for _, v := range []interface{}{uint64(1), "a", uint64(0), uint64(1) << 60} {
+		_, _, err := w.Write(v)
+		assert.NilError(t, err)
+	}
+	footer := make([]byte, footerLen)
+	binary.BigEndian.PutUint64(footer, indexOffset)
+	copy(footer[9:], magic)
+	_, err := w.WriteRaw(footer)
+	assert.NilError(t, err)
+
+	r, err := NewReaderFromBuffer(buf.Bytes())
+	assert.NilError(t, err)
+	_, err = r.Get("a")
+	assert.ErrorContains(t, err, "expected data type mismatch")
+}
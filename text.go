@@ -0,0 +1,307 @@
+package cereal
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The text notation writes each data type distinctly, so parsing it gives the values Reader.Read returns:
+//
+//	bool     true, false
+//	int      -3i
+//	uint     2u
+//	float    1.5f, 1e+100f, -Inff, NaNf
+//	bytes    b64"cmF3"
+//	string   "quoted \"string\""
+//	strings  ["a", "b"]
+//	kvmap    {"a": 1i, "b": ["x"]}
+//
+// Values in a sequence are separated by whitespace. Byte values are formatted as uint, which is how Writer writes them.
+
+// Format will write the value, as returned by Reader.Read, in text notation.
+func Format(v interface{}) (string, error) {
+	var sb strings.Builder
+	if err := formatValue(&sb, v); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func formatValue(sb *strings.Builder, v interface{}) error {
+	switch v := v.(type) {
+	case bool:
+		sb.WriteString(strconv.FormatBool(v))
+	case int64:
+		sb.WriteString(strconv.FormatInt(v, 10) + "i")
+	case uint64:
+		sb.WriteString(strconv.FormatUint(v, 10) + "u")
+	case float64:
+		sb.WriteString(strconv.FormatFloat(v, 'g', -1, 64) + "f")
+	case byte:
+		sb.WriteString(strconv.Itoa(int(v)) + "u")
+	case []byte:
+		sb.WriteString(`b64"` + base64.StdEncoding.EncodeToString(v) + `"`)
+	case string:
+		sb.WriteString(strconv.Quote(v))
+	case []string:
+		sb.WriteByte('[')
+		for i, s := range v {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(strconv.Quote(s))
+		}
+		sb.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		sb.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(strconv.Quote(k) + ": ")
+			if err := formatValue(sb, v[k]); err != nil {
+				return err
+			}
+		}
+		sb.WriteByte('}')
+	default:
+		return fmt.Errorf("cannot format value '%v' of type %T", v, v)
+	}
+	return nil
+}
+
+// Parse will parse a single value in text notation.
+func Parse(text string) (interface{}, error) {
+	values, err := ParseAll(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != 1 {
+		return nil, fmt.Errorf("expected one value, got %d", len(values))
+	}
+	return values[0], nil
+}
+
+// ParseAll will parse a sequence of values in text notation.
+func ParseAll(text string) ([]interface{}, error) {
+	p := &textParser{text: text}
+	var values []interface{}
+	for {
+		p.skipSpace()
+		if p.pos == len(p.text) {
+			return values, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+}
+
+// textParser parses text notation.
+type textParser struct {
+	text string
+	pos  int
+}
+
+func (p *textParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("offset %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *textParser) skipSpace() {
+	for p.pos < len(p.text) && strings.IndexByte(" \t\r\n", p.text[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// expect will skip whitespace and consume the byte.
+func (p *textParser) expect(b byte) error {
+	p.skipSpace()
+	if p.pos == len(p.text) {
+		return p.errorf("expected '%c', got end of text", b)
+	}
+	if p.text[p.pos] != b {
+		return p.errorf("expected '%c', got '%c'", b, p.text[p.pos])
+	}
+	p.pos++
+	return nil
+}
+
+// separator will consume a comma between elements, or the closing byte and return true.
+func (p *textParser) separator(close byte) (bool, error) {
+	switch c := p.peek(); c {
+	case close:
+		p.pos++
+		return true, nil
+	case ',':
+		p.pos++
+		return false, nil
+	case 0:
+		return false, p.errorf("expected ',' or '%c', got end of text", close)
+	default:
+		return false, p.errorf("expected ',' or '%c', got '%c'", close, c)
+	}
+}
+
+// peek will skip whitespace and return the next byte, or 0 at the end of the text.
+func (p *textParser) peek() byte {
+	p.skipSpace()
+	if p.pos == len(p.text) {
+		return 0
+	}
+	return p.text[p.pos]
+}
+
+func (p *textParser) value() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == 0:
+		return nil, p.errorf("expected value, got end of text")
+	case c == '{':
+		return p.keyValueMap()
+	case c == '[':
+		return p.stringSlice()
+	case c == '"':
+		return p.quoted()
+	case strings.HasPrefix(p.text[p.pos:], `b64"`):
+		p.pos += 3
+		start := p.pos
+		s, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		buf, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid base64: %v", err)
+		}
+		return buf, nil
+	default:
+		return p.scalar()
+	}
+}
+
+func (p *textParser) keyValueMap() (interface{}, error) {
+	p.pos++
+	m := map[string]interface{}{}
+	if p.peek() == '}' {
+		p.pos++
+		return m, nil
+	}
+	for {
+		if p.peek() != '"' {
+			return nil, p.errorf("expected quoted key")
+		}
+		start := p.pos
+		k, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := m[k]; ok {
+			p.pos = start
+			return nil, p.errorf("duplicate key %q", k)
+		}
+		if err = p.expect(':'); err != nil {
+			return nil, err
+		}
+		if m[k], err = p.value(); err != nil {
+			return nil, err
+		}
+
+		done, err := p.separator('}')
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return m, nil
+		}
+	}
+}
+
+func (p *textParser) stringSlice() (interface{}, error) {
+	p.pos++
+	s := []string{}
+	if p.peek() == ']' {
+		p.pos++
+		return s, nil
+	}
+	for {
+		if p.peek() != '"' {
+			return nil, p.errorf("expected quoted string in strings")
+		}
+		str, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, str)
+
+		done, err := p.separator(']')
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			return s, nil
+		}
+	}
+}
+
+// quoted will consume a quoted string at the current position.
+func (p *textParser) quoted() (string, error) {
+	start := p.pos
+	for i := start + 1; i < len(p.text); i++ {
+		switch p.text[i] {
+		case '\\':
+			i++
+		case '"':
+			s, err := strconv.Unquote(p.text[start : i+1])
+			if err != nil {
+				return "", p.errorf("invalid quoted string")
+			}
+			p.pos = i + 1
+			return s, nil
+		}
+	}
+	return "", p.errorf("unterminated quoted string")
+}
+
+// scalar will consume a bool or number with a type suffix.
+func (p *textParser) scalar() (interface{}, error) {
+	start := p.pos
+	end := start
+	for end < len(p.text) && strings.IndexByte(" \t\r\n,:]}", p.text[end]) < 0 {
+		end++
+	}
+	tok := p.text[start:end]
+	if tok == "" {
+		return nil, p.errorf("unexpected '%c'", p.text[start])
+	}
+
+	var v interface{}
+	var err error
+	switch num := tok[:len(tok)-1]; {
+	case tok == "true" || tok == "false":
+		v = tok == "true"
+	case strings.HasSuffix(tok, "i"):
+		v, err = strconv.ParseInt(num, 10, 64)
+	case strings.HasSuffix(tok, "u"):
+		v, err = strconv.ParseUint(num, 10, 64)
+	case strings.HasSuffix(tok, "f"):
+		v, err = strconv.ParseFloat(num, 64)
+	default:
+		return nil, p.errorf("invalid value '%s'", tok)
+	}
+	if err != nil {
+		return nil, p.errorf("invalid value '%s'", tok)
+	}
+	p.pos = end
+	return v, nil
+}
@@ -0,0 +1,98 @@
+package cereal
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"gotest.tools/assert"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{true, `true`},
+		{int64(-3), `-3i`},
+		{uint64(math.MaxUint64), `18446744073709551615u`},
+		{1.5, `1.5f`},
+		{1e100, `1e+100f`},
+		{math.Inf(-1), `-Inff`},
+		{[]byte("raw"), `b64"cmF3"`},
+		{[]byte{}, `b64""`},
+		{"say \"hi\"\n", `"say \"hi\"\n"`},
+		{[]string{}, `[]`},
+		{[]string{"a", "b"}, `["a", "b"]`},
+		{map[string]interface{}{}, `{}`},
+		{map[string]interface{}{"b": []string{"x"}, "a": int64(1), "c": map[string]interface{}{"d": false}}, `{"a": 1i, "b": ["x"], "c": {"d": false}}`},
+	}
+	for _, tt := range tests {
+		text, err := Format(tt.value)
+		assert.NilError(t, err)
+		assert.Equal(t, text, tt.expected)
+
+		val, err := Parse(text)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, val, tt.value)
+	}
+
+	// Bytes are written as unsigned integers, so they read back as uint
+	text, err := Format(byte(7))
+	assert.NilError(t, err)
+	assert.Equal(t, text, `7u`)
+
+	_, err = Format(int32(1))
+	assert.Error(t, err, "cannot format value '1' of type int32")
+	_, err = Format(map[string]interface{}{"a": nil})
+	assert.Error(t, err, "cannot format value '<nil>' of type <nil>")
+}
+
+func TestParse_MatchesReader(t *testing.T) {
+	values, err := ParseAll(`
+		{ "a": 1i, "b": 2u, "c": b64"AAE=", "d": { "e": 0.25f, "f": [ "x" , "y" ] } }
+		"text" NaNf 255u
+	`)
+	assert.NilError(t, err)
+	assert.Equal(t, len(values), 4)
+	assert.Assert(t, math.IsNaN(values[2].(float64)))
+
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	_, _, err = w.Write(values[0])
+	assert.NilError(t, err)
+	_, _, err = w.Write(values[1])
+	assert.NilError(t, err)
+	_, _, err = w.Write(values[3])
+	assert.NilError(t, err)
+
+	r := NewReaderFromBuffer(buf.Bytes())
+	for _, expected := range []interface{}{values[0], values[1], values[3]} {
+		val, _, err := r.Read(Any)
+		assert.NilError(t, err)
+		assert.DeepEqual(t, val, expected)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := map[string]string{
+		``:                   "expected one value, got 0",
+		`1i 2i`:              "expected one value, got 2",
+		`1`:                  "offset 0: invalid value '1'",
+		`7b`:                 "offset 0: invalid value '7b'",
+		`1e999f`:             "offset 0: invalid value '1e999f'",
+		`-1u`:                "offset 0: invalid value '-1u'",
+		`{"a": 1i`:           "offset 8: expected ',' or '}', got end of text",
+		`{"a" 1i}`:           "offset 5: expected ':', got '1'",
+		`{"a": 1i, "a": 2i}`: "offset 10: duplicate key \"a\"",
+		`{a: 1i}`:            "offset 1: expected quoted key",
+		`["a", 1i]`:          "offset 6: expected quoted string in strings",
+		`"open`:              "offset 0: unterminated quoted string",
+		`b64"!!"`:            "offset 3: invalid base64: illegal base64 data at input byte 0",
+		`{"a": }`:            "offset 6: unexpected '}'",
+	}
+	for text, expected := range tests {
+		_, err := Parse(text)
+		assert.Error(t, err, expected, text)
+	}
+}
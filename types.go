@@ -17,17 +17,32 @@ const (
 	String
 	StringSlice
 	KeyValueMap
+	CompressedBytes
+	CompressedString
 )
 
 var dataTypeStrings = map[DataType]string{
-	Any:             "any",
-	Boolean:         "bool",
-	Integer:         "int",
-	UnsignedInteger: "uint",
-	Float:           "float",
-	Byte:            "byte",
-	Bytes:           "bytes",
-	String:          "string",
-	StringSlice:     "strings",
-	KeyValueMap:     "kvmap",
+	Any:              "any",
+	Boolean:          "bool",
+	Integer:          "int",
+	UnsignedInteger:  "uint",
+	Float:            "float",
+	Byte:             "byte",
+	Bytes:            "bytes",
+	String:           "string",
+	StringSlice:      "strings",
+	KeyValueMap:      "kvmap",
+	CompressedBytes:  "cbytes",
+	CompressedString: "cstring",
+}
+
+// uncompressedType returns the type of the value stored by a compressed data type.
+func uncompressedType(d DataType) DataType {
+	switch d {
+	case CompressedBytes:
+		return Bytes
+	case CompressedString:
+		return String
+	}
+	return d
 }
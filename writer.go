@@ -8,15 +8,12 @@ import (
 	"io"
 	"os"
 	"sort"
-	"reflect"
-
-	"github.com/pierrec/lz4"
 )
 
 var (
 	// LZ4 properties
-	hashTable    [64 << 10]int
-	lz4BlockSize = 64 << 10
+	lz4HashTableSize = 64 << 10
+	lz4BlockSize     = 64 << 10
 )
 
 type Writer struct {
@@ -25,6 +22,29 @@ type Writer struct {
 	file             *os.File
 	reusableBuf      []byte
 	excludeWriteType bool
+
+	compressionWorkers int
+
+	// Bytes and strings of at least valueCompressionThreshold bytes are compressed with valueCodec
+	valueCodec                Codec
+	valueCompressionThreshold int
+
+	// Key used to sign the content when the writer is closed
+	signingKey interface{}
+
+	// Offsets of top-level values, written as an index when the writer is closed
+	indexed bool
+	index   []IndexEntry
+
+	// Bits per key of the Bloom filter written with the index, or 0 for no filter
+	filterBitsPerKey int
+
+	// Encoder writing values in another wire format, or nil to write cereal values
+	valueEncoder ValueEncoder
+
+	// Depth of the value being written and number of open sections
+	depth    int
+	sections int
 }
 
 // NewWriter will return a new writer.
@@ -52,6 +72,30 @@ func (w *Writer) SetExcludeWriteType(b bool) {
 	w.excludeWriteType = b
 }
 
+// SetCompressionWorkers will set the number of blocks that compressed sections started afterwards compress in parallel.
+func (w *Writer) SetCompressionWorkers(n int) {
+	w.compressionWorkers = n
+}
+
+// SetValueCompression will compress Bytes and String values of at least threshold bytes with the codec, when doing
+// so makes them smaller. A nil codec disables value compression.
+func (w *Writer) SetValueCompression(codec Codec, threshold int) {
+	w.valueCodec = codec
+	w.valueCompressionThreshold = threshold
+}
+
+// ValueEncoder writes values in a wire format other than cereal's.
+type ValueEncoder interface {
+	// EncodeValue writes the complete encoding of the value to w.
+	EncodeValue(w io.Writer, v interface{}) error
+}
+
+// SetValueEncoder will write values passed to Write with the encoder instead of in cereal format. Data types are not
+// written and value compression does not apply. A nil encoder restores cereal format.
+func (w *Writer) SetValueEncoder(e ValueEncoder) {
+	w.valueEncoder = e
+}
+
 func (w *Writer) SeekOffset(offset uint64) error {
 	if w.file != nil {
 		_, err := w.file.Seek(int64(offset), io.SeekStart)
@@ -63,31 +107,41 @@ func (w *Writer) SeekOffset(offset uint64) error {
 func (w *Writer) Write(data interface{}) (offset uint64, length int, err error) {
 	offset = w.w.Count()
 
-	switch vv := data.(type) {
-	case uint, uint8, uint16, uint32, uint64:
-		offset, err = w.writeUint(uint64Value(vv))
-	case int, int8, int16, int32, int64:
-		offset, err = w.writeInt(int64Value(vv))
-	case float32, float64:
-		offset, err = w.writeFloat(floatValue(vv))
-	case []byte:
-		offset, err = w.writeBytes(vv)
-	case string:
-		offset, err = w.writeString(vv)
-	case []string:
-		offset, err = w.writeStringSlice(vv)
-	case bool:
-		offset, err = w.writeBoolean(vv)
-	case map[string]interface{}:
-		offset, err = w.writeKeyValueMap(vv)
-	default:
-		panic(fmt.Errorf("cannot write value, unknown data type for value: '%v' (type: %s)", vv, reflect.TypeOf(vv).String()))
+	// Values in another wire format are written whole by the encoder
+	if w.valueEncoder != nil {
+		err = w.valueEncoder.EncodeValue(w.w, data)
+	} else {
+		switch vv := data.(type) {
+		case uint, uint8, uint16, uint32, uint64:
+			offset, err = w.writeUint(uint64Value(vv))
+		case int, int8, int16, int32, int64:
+			offset, err = w.writeInt(int64Value(vv))
+		case float32, float64:
+			offset, err = w.writeFloat(floatValue(vv))
+		case []byte:
+			offset, err = w.writeBytes(vv)
+		case string:
+			offset, err = w.writeString(vv)
+		case []string:
+			offset, err = w.writeStringSlice(vv)
+		case bool:
+			offset, err = w.writeBoolean(vv)
+		case map[string]interface{}:
+			offset, err = w.writeKeyValueMap(vv)
+		default:
+			err = fmt.Errorf("cannot write value, unknown data type for value: '%v' (type: %T)", vv, vv)
+		}
 	}
 
 	if err != nil {
 		return 0, 0, err
 	}
 	length = int(w.w.Count() - offset)
+
+	// Record top-level values outside of sections in the index
+	if w.indexed && w.depth == 0 && w.sections == 0 {
+		w.index = append(w.index, IndexEntry{Offset: offset})
+	}
 	return offset, length, err
 }
 
@@ -98,30 +152,20 @@ func (w *Writer) WriteRaw(buf []byte) (offset uint64, err error) {
 	return offset, err
 }
 
-// WriteRawToCompress will write raw bytes to compress into LZ4, then to the writer.
+// WriteRawToLZ4Compress will compress the raw bytes into LZ4 blocks and write them to the writer as a compressed section.
 func (w *Writer) WriteRawToLZ4Compress(buf []byte) (offset uint64, length int, err error) {
-	currentOffset := w.w.Count()
-	zbuf := make([]byte, lz4BlockSize)
-	chunkData := make([]byte, lz4BlockSize)
-
-	r := bytes.NewReader(buf)
-	for {
-		// Read chunk
-		n, err := r.Read(chunkData)
-		if err != nil && err != io.EOF {
-			return 0, 0, err
-		}
-		if n == 0 {
-			break
-		}
+	offset = w.w.Count()
 
-		compSize, err := lz4.CompressBlock(chunkData, zbuf, hashTable[:])
-		if _, err = w.WriteRaw(zbuf[0:compSize]); err != nil {
-			return 0, 0, err
-		}
+	cw := w.BeginCompressed(LZ4)
+	if _, err = cw.Write(buf); err != nil {
+		cw.Close()
+		return 0, 0, err
+	}
+	if err = cw.Close(); err != nil {
+		return 0, 0, err
 	}
 
-	return currentOffset, int(w.w.Count() - currentOffset), nil
+	return offset, int(w.w.Count() - offset), nil
 }
 
 // WriteRawByte will write a single byte into the writer.
@@ -194,14 +238,18 @@ func (w *Writer) writeFloat(v interface{}) (offset uint64, err error) {
 	return offset, nil
 }
 
-func (w *Writer) appendBytes(b []byte) (err error) {
+func (w *Writer) appendUvarint(v uint64) (err error) {
 	if len(w.reusableBuf) < binary.MaxVarintLen64 {
 		w.reusableBuf = make([]byte, binary.MaxVarintLen64)
 	}
+	size := binary.PutUvarint(w.reusableBuf, v)
+	_, err = w.w.Write(w.reusableBuf[0:size])
+	return err
+}
 
+func (w *Writer) appendBytes(b []byte) (err error) {
 	// Write length
-	size := binary.PutUvarint(w.reusableBuf, uint64(len(b)))
-	if _, err = w.w.Write(w.reusableBuf[0:size]); err != nil {
+	if err = w.appendUvarint(uint64(len(b))); err != nil {
 		return err
 	}
 
@@ -216,6 +264,11 @@ func (w *Writer) appendBytes(b []byte) (err error) {
 func (w *Writer) writeString(s string) (offset uint64, err error) {
 	offset = w.w.Count()
 
+	// Write compressed string
+	if ok, err := w.writeCompressedValue(CompressedString, []byte(s)); ok || err != nil {
+		return offset, err
+	}
+
 	// Write type
 	if !w.excludeWriteType {
 		if err = w.w.WriteByte(byte(String)); err != nil {
@@ -289,6 +342,8 @@ func (w *Writer) writeKeyValueMap(m map[string]interface{}) (offset uint64, err
 	sort.Strings(keys)
 
 	// Write key-values
+	w.depth++
+	defer func() { w.depth-- }()
 	for _, k := range keys {
 		w.excludeWriteType = true
 		if _, err = w.writeString(k); err != nil {
@@ -331,6 +386,11 @@ func (w *Writer) writeBoolean(b bool) (offset uint64, err error) {
 func (w *Writer) writeBytes(b []byte) (offset uint64, err error) {
 	offset = w.w.Count()
 
+	// Write compressed bytes
+	if ok, err := w.writeCompressedValue(CompressedBytes, b); ok || err != nil {
+		return offset, err
+	}
+
 	// Write type
 	if !w.excludeWriteType {
 		if err = w.w.WriteByte(byte(Bytes)); err != nil {
@@ -345,8 +405,60 @@ func (w *Writer) writeBytes(b []byte) (offset uint64, err error) {
 	return offset, nil
 }
 
-// Close will close the writer.
+// writeCompressedValue will write the bytes as a compressed section if value compression is enabled and it is
+// smaller than writing them uncompressed, returning whether the value was written.
+func (w *Writer) writeCompressedValue(t DataType, b []byte) (ok bool, err error) {
+	// Values without a type, such as map keys, are always read uncompressed
+	if w.valueCodec == nil || w.excludeWriteType || len(b) < w.valueCompressionThreshold {
+		return false, nil
+	}
+
+	buf := new(bytes.Buffer)
+	tmp := NewWriterFromBuffer(buf)
+	tmp.compressionWorkers = w.compressionWorkers
+	cw := tmp.BeginCompressed(w.valueCodec)
+	if _, err = cw.Write(b); err != nil {
+		return false, err
+	}
+	if err = cw.Close(); err != nil {
+		return false, err
+	}
+
+	// Only compress when it shrinks the value
+	size := binary.PutUvarint(make([]byte, binary.MaxVarintLen64), uint64(len(b)))
+	if buf.Len() >= size+len(b) {
+		return false, nil
+	}
+
+	// Write type
+	if err = w.w.WriteByte(byte(t)); err != nil {
+		return false, err
+	}
+
+	// Write compressed section
+	if _, err = w.w.Write(buf.Bytes()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Close will write the index if enabled and the signature if a signing key has been set, then close the writer. Open
+// compressed and encrypted sections must be closed first.
 func (w *Writer) Close() error {
+	if w.sections != 0 {
+		return fmt.Errorf("cannot close writer while a section is open")
+	}
+	if w.indexed {
+		if err := w.writeIndex(); err != nil {
+			return err
+		}
+	}
+	if w.signingKey != nil {
+		if err := w.writeSignature(); err != nil {
+			return err
+		}
+	}
+
 	if w.file != nil {
 		return w.file.Close()
 	}
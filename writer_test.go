@@ -2,6 +2,8 @@ package cereal
 
 import (
 	"bytes"
+	"math/rand"
+	"strings"
 	"testing"
 
 	"gotest.tools/assert"
@@ -77,6 +79,14 @@ func TestWriter_Write(t *testing.T) {
 				offsets: []uint64{0},
 			},
 		},
+		{
+			name: "float32",
+			data: []interface{}{float32(1.25)},
+			expected: expected{
+				bytes:   []byte{0x04, 0x3f, 0xf4, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+				offsets: []uint64{0},
+			},
+		},
 		{
 			name: "boolean",
 			data: []interface{}{true, false},
@@ -132,3 +142,68 @@ func TestWriter_WriteMap(t *testing.T) {
 	assert.Equal(t, dt, KeyValueMap)
 	assert.DeepEqual(t, val, m)
 }
+
+func TestWriter_ValueCompression(t *testing.T) {
+	text := strings.Repeat("all work and no play makes jack a dull boy. ", 1000)
+	random := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	m := map[string]interface{}{
+		"id":     uint64(7),
+		"text":   text,
+		"blob":   []byte(text),
+		"random": random,
+		"short":  "aaaaaaaaaaaaaaaa",
+	}
+
+	buf := new(bytes.Buffer)
+	w := NewWriterFromBuffer(buf)
+	w.SetValueCompression(LZ4, 1024)
+	_, length, err := w.Write(m)
+	assert.NilError(t, err)
+	assert.Assert(t, length < len(text)+len(random))
+
+	r := NewReaderFromBuffer(buf.Bytes())
+	val, dt, err := r.Read(KeyValueMap)
+	assert.NilError(t, err)
+	assert.Equal(t, dt, KeyValueMap)
+	assert.DeepEqual(t, val, m)
+
+	tests := []struct {
+		name     string
+		val      interface{}
+		dataType DataType
+		stored   DataType
+	}{
+		{name: "compressible string", val: text, dataType: String, stored: CompressedString},
+		{name: "compressible bytes", val: []byte(text), dataType: Bytes, stored: CompressedBytes},
+		{name: "incompressible bytes", val: random, dataType: Bytes, stored: Bytes},
+		{name: "below threshold", val: strings.Repeat("a", 1023), dataType: String, stored: String},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w := NewWriterFromBuffer(buf)
+			w.SetValueCompression(Deflate, 1024)
+			_, _, err := w.Write(test.val)
+			assert.NilError(t, err)
+			assert.Equal(t, DataType(buf.Bytes()[0]), test.stored)
+
+			r := NewReaderFromBuffer(buf.Bytes())
+			val, dt, err := r.Read(test.dataType)
+			assert.NilError(t, err)
+			assert.Equal(t, dt, test.dataType)
+			assert.DeepEqual(t, val, test.val)
+		})
+	}
+}
+
+func TestWriter_WriteUnknownType(t *testing.T) {
+	w := NewWriterFromBuffer(new(bytes.Buffer))
+	_, _, err := w.Write([]int{1})
+	assert.Error(t, err, "cannot write value, unknown data type for value: '[1]' (type: []int)")
+	_, _, err = w.Write(nil)
+	assert.Error(t, err, "cannot write value, unknown data type for value: '<nil>' (type: <nil>)")
+	_, _, err = w.Write(map[string]interface{}{"a": struct{}{}})
+	assert.Error(t, err, "cannot write value, unknown data type for value: '{}' (type: struct {})")
+}